@@ -0,0 +1,294 @@
+package sbf
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+
+	"github.com/zeebo/xxh3"
+)
+
+// Search bounds of NewDefaultAgePartitionedFilter.
+const (
+	apbfMaxK = 32      // Largest number of slices written per insertion
+	apbfMaxL = 1 << 12 // Largest number of extra slices
+)
+
+// AgePartitionedFilter is an age-partitioned Bloom filter over a sliding window of insertions.
+//
+// It follows Shtul, Baquero and Almeida, "Age-Partitioned Bloom Filters" (2020). The filter is a
+// circular list of k+l slices of m bits, each with its own hash function. Add sets one bit in each
+// of the k newest slices, and Check reports an element if some k consecutive slices all have its
+// bit set. Every g insertions the oldest slice is cleared and becomes the newest, so an element
+// moves towards the old end as the window slides. It is reported for at least the l·g most recent
+// insertions, itself included, and forgotten after at most (l+1)·g.
+//
+// Unlike the generations of WindowedFilter, every insertion spreads over k slices, so the false
+// positive rate stays close to that of a single Bloom filter for the window. No background
+// goroutine is used. It is safe for concurrent use by multiple goroutines.
+type AgePartitionedFilter struct {
+	k, l          uint32
+	m             uint32   // Size of each slice in bits
+	g             uint64   // Insertions per generation
+	wordsPerSlice uint32   // Words per slice
+	slices        []uint64 // k+l slices of m bits, back to back
+	base          atomic.Uint32
+	adds          atomic.Uint64 // Adds since the filter was created
+
+	shiftMu sync.Mutex
+	shifts  atomic.Uint64
+}
+
+// NewAgePartitionedFilter creates an age-partitioned Bloom filter.
+//
+// With m = k·g/ln 2 the slices are at most half full, which is the sizing analyzed in the paper
+// and used by NewDefaultAgePartitionedFilter.
+//
+// Parameters:
+//   - m: Size of each slice in bits.
+//   - k: Number of slices written per insertion.
+//   - l: Number of extra slices; together with g it sets the window to l·g insertions.
+//   - generationItems: Number of insertions g after which the oldest slice is cleared.
+//
+// Returns:
+//   - A pointer to the AgePartitionedFilter.
+//   - An error if the parameters are invalid.
+func NewAgePartitionedFilter(m, k, l uint32, generationItems uint64) (*AgePartitionedFilter, error) {
+	if m == 0 {
+		return nil, errors.New("filter size m must be greater than 0")
+	}
+	if k == 0 || l == 0 {
+		return nil, errors.New("k and l must be greater than 0")
+	}
+	if generationItems == 0 {
+		return nil, errors.New("generation must contain at least one item")
+	}
+
+	// Ensure m is a multiple of 64 for alignment
+	if m%64 != 0 {
+		m += 64 - (m % 64)
+	}
+	numSlices := uint64(k) + uint64(l)
+	if numSlices*uint64(m) > math.MaxUint32 {
+		return nil, errors.New("filter is too large")
+	}
+
+	return &AgePartitionedFilter{
+		k:             k,
+		l:             l,
+		m:             m,
+		g:             generationItems,
+		wordsPerSlice: m / 64,
+		slices:        make([]uint64, numSlices*uint64(m/64)),
+	}, nil
+}
+
+// NewDefaultAgePartitionedFilter creates an age-partitioned Bloom filter for a window of the most
+// recent windowItems insertions.
+//
+// It picks the k and l that need the fewest bits while keeping AgePartitionedFalsePositiveRate(k, l)
+// within falsePositiveRate, and sizes the slices so they are at most half full.
+//
+// Parameters:
+//   - windowItems: Number of most recent insertions that are always reported.
+//   - falsePositiveRate: Desired false positive rate (between 0 and 1).
+//
+// Returns:
+//   - A pointer to the AgePartitionedFilter.
+//   - An error if the parameters are invalid.
+func NewDefaultAgePartitionedFilter(windowItems uint64, falsePositiveRate float64) (*AgePartitionedFilter, error) {
+	if windowItems == 0 {
+		return nil, errors.New("window must contain at least one item")
+	}
+	if falsePositiveRate <= 0.0 || falsePositiveRate >= 1.0 {
+		return nil, errors.New("false positive rate p must be between 0 and 1 (exclusive)")
+	}
+
+	var bestK, bestL uint32
+	bestBits := uint64(math.MaxUint64)
+	for k := uint32(1); k <= apbfMaxK; k++ {
+		// The false positive rate grows with l, so extend the filter one slice at a time
+		dist := make([]float64, k)
+		dist[0] = 1
+		var fpr float64
+		for i := uint32(0); i < k; i++ {
+			fpr += runStep(dist, apbfFillAtCapacity(i, k))
+		}
+		for l := uint32(1); l <= apbfMaxL && uint64(l) <= windowItems; l++ {
+			fpr += runStep(dist, 0.5)
+			if fpr > falsePositiveRate {
+				break
+			}
+			g := (windowItems + uint64(l) - 1) / uint64(l)
+			if total := uint64(k+l) * apbfSliceBits(k, g); total < bestBits {
+				bestK, bestL, bestBits = k, l, total
+			}
+		}
+	}
+	if bestK == 0 {
+		return nil, errors.New("false positive rate is too small")
+	}
+
+	g := (windowItems + uint64(bestL) - 1) / uint64(bestL)
+	m := apbfSliceBits(bestK, g)
+	if m > math.MaxUint32-63 {
+		return nil, errors.New("window is too large")
+	}
+	return NewAgePartitionedFilter(uint32(m), bestK, bestL, g)
+}
+
+// Add inserts an element into the k newest slices.
+func (a *AgePartitionedFilter) Add(data []byte) {
+	h1, h2 := apbfHash(data)
+	base := a.base.Load()
+	n := a.k + a.l
+	for j := uint32(0); j < a.k; j++ {
+		p := (base + j) % n
+		idx := a.index(h1, h2, p)
+		atomicOr(&a.slices[idx/64], uint64(1)<<(idx%64))
+	}
+
+	if a.adds.Add(1)%a.g == 0 {
+		a.shift()
+	}
+}
+
+// Check tests if an element might be among the recent insertions.
+//
+// Returns true if the element might be in the filter, or false if the element is definitely not in the filter.
+func (a *AgePartitionedFilter) Check(data []byte) bool {
+	h1, h2 := apbfHash(data)
+	base := a.base.Load()
+	n := a.k + a.l
+	run := uint32(0)
+	for i := uint32(0); i < n; i++ {
+		idx := a.index(h1, h2, (base+i)%n)
+		if !atomicGetBit(&a.slices[idx/64], idx%64) {
+			run = 0
+			// Too few slices remain to complete a run
+			if n-i-1 < a.k {
+				return false
+			}
+			continue
+		}
+		if run++; run == a.k {
+			return true
+		}
+	}
+	return false
+}
+
+// Window returns the number of most recent insertions l·g that are always reported.
+func (a *AgePartitionedFilter) Window() uint64 {
+	return uint64(a.l) * a.g
+}
+
+// Shifts returns the number of times the oldest slice was cleared.
+func (a *AgePartitionedFilter) Shifts() uint64 {
+	return a.shifts.Load()
+}
+
+// EstimateFalsePositiveRate estimates the current false positive rate of the AgePartitionedFilter.
+//
+// An element is reported present if k consecutive slices have its bit set, so the estimate is the
+// probability of a run of k hits when slice i, from newest to oldest, is hit with its fill ratio.
+func (a *AgePartitionedFilter) EstimateFalsePositiveRate() float64 {
+	base := a.base.Load()
+	n := a.k + a.l
+	dist := make([]float64, a.k)
+	dist[0] = 1
+	var fpr float64
+	for i := uint32(0); i < n; i++ {
+		start := ((base + i) % n) * a.wordsPerSlice
+		var bitsSet uint64
+		for j := start; j < start+a.wordsPerSlice; j++ {
+			bitsSet += uint64(bits.OnesCount64(atomic.LoadUint64(&a.slices[j])))
+		}
+		fpr += runStep(dist, float64(bitsSet)/float64(a.m))
+	}
+	return fpr
+}
+
+// StopDecay has no effect, since an AgePartitionedFilter shifts on insertion.
+//
+// It exists so the filter satisfies the Filter interface.
+func (a *AgePartitionedFilter) StopDecay() {}
+
+// AgePartitionedFalsePositiveRate returns the false positive rate of an age-partitioned Bloom
+// filter with k and l slices whose slices are sized to be half full, m = k·g/ln 2.
+//
+// It is the rate right before a shift, the highest it gets: the slice that received j of its k
+// generations has fill ratio 1 - 2^(-j/k), and the rate is the probability of k consecutive hits
+// across the k+l slices.
+func AgePartitionedFalsePositiveRate(k, l uint32) float64 {
+	if k == 0 {
+		return 1
+	}
+	dist := make([]float64, k)
+	dist[0] = 1
+	var fpr float64
+	for i := uint32(0); i < k+l; i++ {
+		fpr += runStep(dist, apbfFillAtCapacity(i, k))
+	}
+	return fpr
+}
+
+// shift clears the oldest slice and makes it the newest.
+func (a *AgePartitionedFilter) shift() {
+	a.shiftMu.Lock()
+	defer a.shiftMu.Unlock()
+
+	n := a.k + a.l
+	next := (a.base.Load() + n - 1) % n
+	start := next * a.wordsPerSlice
+	for i := start; i < start+a.wordsPerSlice; i++ {
+		atomic.StoreUint64(&a.slices[i], 0)
+	}
+	a.base.Store(next)
+	a.shifts.Add(1)
+}
+
+// index returns the bit index of an element in physical slice p, using double hashing.
+func (a *AgePartitionedFilter) index(h1, h2 uint64, p uint32) uint32 {
+	return p*a.m + uint32((h1+uint64(p)*h2)%uint64(a.m))
+}
+
+// apbfHash returns the two halves of the 128-bit hash of an element.
+func apbfHash(data []byte) (h1, h2 uint64) {
+	h := xxh3.Hash128(data)
+	return h.Lo, h.Hi
+}
+
+// apbfSliceBits returns the slice size that keeps a slice at most half full after receiving k
+// generations of g insertions, rounded up to whole words.
+func apbfSliceBits(k uint32, g uint64) uint64 {
+	m := uint64(math.Ceil(float64(k) * float64(g) / math.Ln2))
+	return (m + 63) &^ 63
+}
+
+// apbfFillAtCapacity returns the fill ratio of the i-th newest slice right before a shift, for
+// slices sized to be half full after k generations.
+func apbfFillAtCapacity(i, k uint32) float64 {
+	generations := min(i+1, k)
+	return 1 - math.Exp2(-float64(generations)/float64(k))
+}
+
+// runStep advances the distribution of the current run of hits by one slice hit with probability
+// p, and returns the probability of completing a run of len(dist) hits at this slice.
+//
+// dist[r] is the probability that the last r slices were hits and no run was completed yet.
+func runStep(dist []float64, p float64) float64 {
+	k := len(dist)
+	completed := dist[k-1] * p
+	var miss float64
+	for r := k - 1; r >= 0; r-- {
+		miss += dist[r] * (1 - p)
+		if r > 0 {
+			dist[r] = dist[r-1] * p
+		}
+	}
+	dist[0] = miss
+	return completed
+}
@@ -0,0 +1,140 @@
+package sbf
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestAgePartitionedFilterWindow(t *testing.T) {
+	a, err := NewAgePartitionedFilter(1<<12, 4, 6, 100)
+	if err != nil {
+		t.Fatalf("Failed to create AgePartitionedFilter: %v", err)
+	}
+	defer a.StopDecay()
+
+	if got := a.Window(); got != 600 {
+		t.Fatalf("Expected a window of 600, got %d", got)
+	}
+
+	const total = 3000
+	for i := 0; i < total; i++ {
+		a.Add([]byte(fmt.Sprintf("element_%d", i)))
+
+		// Every element within the window is reported
+		for j := max(0, i-int(a.Window())+1); j <= i; j += 37 {
+			if !a.Check([]byte(fmt.Sprintf("element_%d", j))) {
+				t.Fatalf("element_%d missing after %d insertions", j, i+1)
+			}
+		}
+	}
+	if got := a.Shifts(); got != total/100 {
+		t.Errorf("Expected %d shifts, got %d", total/100, got)
+	}
+
+	// Elements older than (l+1)·g insertions are forgotten, up to false positives
+	forgotten := 0
+	for j := 0; j < total-700; j++ {
+		if !a.Check([]byte(fmt.Sprintf("element_%d", j))) {
+			forgotten++
+		}
+	}
+	if forgotten < (total-700)*9/10 {
+		t.Errorf("Only %d of %d expired elements were forgotten", forgotten, total-700)
+	}
+}
+
+func TestAgePartitionedFilterFalsePositiveRate(t *testing.T) {
+	a, err := NewDefaultAgePartitionedFilter(10000, 0.01)
+	if err != nil {
+		t.Fatalf("Failed to create AgePartitionedFilter: %v", err)
+	}
+	defer a.StopDecay()
+
+	if bound := AgePartitionedFalsePositiveRate(a.k, a.l); bound > 0.01 {
+		t.Fatalf("Chose k=%d l=%d with false positive rate %f above the target", a.k, a.l, bound)
+	}
+	if a.Window() < 10000 {
+		t.Fatalf("Window %d is smaller than requested", a.Window())
+	}
+
+	for i := 0; i < 50000; i++ {
+		a.Add([]byte(fmt.Sprintf("element_%d", i)))
+	}
+	estimated := a.EstimateFalsePositiveRate()
+	if estimated > 0.01*1.2 {
+		t.Errorf("Estimated false positive rate %f above the target", estimated)
+	}
+
+	falsePositives := 0
+	const trials = 100000
+	for i := 0; i < trials; i++ {
+		if a.Check([]byte(fmt.Sprintf("absent_%d", i))) {
+			falsePositives++
+		}
+	}
+	if observed := float64(falsePositives) / trials; observed > 0.015 {
+		t.Errorf("Observed false positive rate %f, target 0.01 (estimate %f)", observed, estimated)
+	}
+}
+
+func TestAgePartitionedFalsePositiveRateFormula(t *testing.T) {
+	// With l = 0 only the k newest slices exist, so the rate is the product of their fill ratios
+	want := 1.0
+	for j := 1; j <= 3; j++ {
+		want *= 1 - math.Exp2(-float64(j)/3)
+	}
+	if got := AgePartitionedFalsePositiveRate(3, 0); math.Abs(got-want) > 1e-12 {
+		t.Errorf("Expected %g, got %g", want, got)
+	}
+
+	// More slices give more chances for a false run
+	prev := 0.0
+	for l := uint32(1); l <= 20; l++ {
+		fpr := AgePartitionedFalsePositiveRate(10, l)
+		if fpr <= prev {
+			t.Errorf("False positive rate did not grow with l=%d: %g <= %g", l, fpr, prev)
+		}
+		prev = fpr
+	}
+}
+
+func TestRunStep(t *testing.T) {
+	// Two hits in a row over three fair coin flips: HH?, THH → 1/4 + 1/8
+	dist := make([]float64, 2)
+	dist[0] = 1
+	var p float64
+	for i := 0; i < 3; i++ {
+		p += runStep(dist, 0.5)
+	}
+	if math.Abs(p-0.375) > 1e-12 {
+		t.Errorf("Expected 0.375, got %g", p)
+	}
+}
+
+func TestAgePartitionedFilterInvalidParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		m, k, l uint32
+		g       uint64
+	}{
+		{"zero size", 0, 4, 4, 100},
+		{"zero k", 1024, 0, 4, 100},
+		{"zero l", 1024, 4, 0, 100},
+		{"zero generation", 1024, 4, 4, 0},
+		{"too large", 1 << 31, 4, 4, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewAgePartitionedFilter(tt.m, tt.k, tt.l, tt.g); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+	if _, err := NewDefaultAgePartitionedFilter(0, 0.01); err == nil {
+		t.Error("Expected an error for an empty window")
+	}
+	if _, err := NewDefaultAgePartitionedFilter(1000, 1.5); err == nil {
+		t.Error("Expected an error for an invalid false positive rate")
+	}
+}
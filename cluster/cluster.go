@@ -0,0 +1,334 @@
+// Package cluster replicates a Stable Bloom Filter across nodes with push-pull anti-entropy.
+//
+// Each Node periodically connects to a peer and both sides exchange the filter words that gained
+// bits since their last exchange (see sbf.StableBloomFilter.Delta). Received words are merged with
+// a bitwise OR, so a fleet of nodes converges on one logical set of recent items while each node
+// keeps decaying its own copy independently.
+//
+// The protocol runs over any net.Conn. Each exchange is:
+//
+//	initiator -> responder: hello frame (incarnation, node ID)
+//	responder -> initiator: hello frame (incarnation, node ID)
+//	initiator -> responder: delta frame
+//	responder -> initiator: delta frame
+//	initiator -> responder: ack frame (empty)
+//
+// where every frame is a 4-byte big-endian length followed by its payload. Each side applies the
+// peer's delta before sending the next frame, so a side only records its delta as delivered once
+// the following frame arrives. If an exchange fails midway, the unacknowledged words are sent again
+// in the next exchange.
+//
+// A hello frame holds the node's incarnation as 8 big-endian bytes followed by its ID. The
+// incarnation is drawn at random when the Node is created, so a peer that restarts under the same
+// ID, possibly with an empty filter, is sent every set word instead of only the changes since its
+// previous incarnation.
+package cluster
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Alfex4936/sbf-go"
+)
+
+// maxFrameSize bounds the size of a single frame read from a peer.
+const maxFrameSize = 1 << 28
+
+// DefaultTimeout is the default deadline for a single exchange.
+const DefaultTimeout = 10 * time.Second
+
+// Node shares a Stable Bloom Filter with peer nodes.
+type Node struct {
+	id     string
+	filter *sbf.StableBloomFilter
+
+	// Timeout is the deadline for a single exchange. Zero means DefaultTimeout.
+	Timeout time.Duration
+	// OnError, if set, is called with errors from background exchanges.
+	OnError func(peer string, err error)
+
+	incarnation uint64 // Random ID of this Node instance, sent in hello frames
+
+	mu   sync.Mutex
+	sent map[string]peerState // Delivery state of each peer ID
+}
+
+// peerState records the filter version last shipped to an incarnation of a peer.
+type peerState struct {
+	incarnation uint64
+	version     uint64
+}
+
+// NewNode creates a node identified by id that replicates the given filter.
+//
+// It enables delta tracking on the filter. Every node in a cluster must use a filter with the
+// same size and hash functions, and a unique id.
+func NewNode(id string, f *sbf.StableBloomFilter) *Node {
+	f.EnableDeltaTracking()
+	return &Node{
+		id:          id,
+		filter:      f,
+		incarnation: rand.Uint64(),
+		sent:        make(map[string]peerState),
+	}
+}
+
+// ID returns the node's identifier.
+func (n *Node) ID() string {
+	return n.id
+}
+
+// Serve accepts connections on ln and answers exchanges initiated by peers.
+//
+// It blocks until ln is closed, and returns nil in that case.
+func (n *Node) Serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			if err := n.respond(conn); err != nil {
+				n.reportError(conn.RemoteAddr().String(), err)
+			}
+		}()
+	}
+}
+
+// Exchange performs one push-pull exchange with the peer on the other end of conn.
+//
+// The caller owns conn and is responsible for closing it.
+func (n *Node) Exchange(conn net.Conn) error {
+	if err := conn.SetDeadline(time.Now().Add(n.timeout())); err != nil {
+		return err
+	}
+
+	if err := n.writeHello(conn); err != nil {
+		return err
+	}
+	peer, incarnation, err := readHello(conn)
+	if err != nil {
+		return err
+	}
+
+	local, err := n.filter.Delta(n.sentTo(peer, incarnation))
+	if err != nil {
+		return err
+	}
+	if err := writeDelta(conn, local); err != nil {
+		return err
+	}
+	remote, err := readDelta(conn)
+	if err != nil {
+		return err
+	}
+	if err := n.filter.ApplyDelta(remote); err != nil {
+		return err
+	}
+	// The responder applied our delta before sending its own
+	n.markSent(peer, incarnation, local.Next)
+
+	return writeFrame(conn, nil)
+}
+
+// SyncWith dials addr over TCP and performs one exchange with the node listening there.
+func (n *Node) SyncWith(ctx context.Context, addr string) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return n.Exchange(conn)
+}
+
+// Run exchanges with a randomly chosen peer address every interval until ctx is done.
+//
+// Errors are reported to OnError and do not stop the loop.
+func (n *Node) Run(ctx context.Context, peers []string, interval time.Duration) {
+	if len(peers) == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			addr := peers[rand.Intn(len(peers))]
+			if err := n.SyncWith(ctx, addr); err != nil && ctx.Err() == nil {
+				n.reportError(addr, err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// respond answers an exchange initiated by a peer.
+func (n *Node) respond(conn net.Conn) error {
+	if err := conn.SetDeadline(time.Now().Add(n.timeout())); err != nil {
+		return err
+	}
+
+	peer, incarnation, err := readHello(conn)
+	if err != nil {
+		return err
+	}
+	if err := n.writeHello(conn); err != nil {
+		return err
+	}
+	remote, err := readDelta(conn)
+	if err != nil {
+		return err
+	}
+
+	// Compute our delta before applying the peer's so this reply holds only our own changes. Words
+	// that gain bits from the peer's delta are stamped with a new version and sent back in the next
+	// exchange; that round trip is harmless because applying a delta is idempotent.
+	local, err := n.filter.Delta(n.sentTo(peer, incarnation))
+	if err != nil {
+		return err
+	}
+	if err := n.filter.ApplyDelta(remote); err != nil {
+		return err
+	}
+	if err := writeDelta(conn, local); err != nil {
+		return err
+	}
+
+	// Only an acknowledgement proves the initiator applied our delta
+	ack, err := readFrame(conn)
+	if err != nil {
+		return err
+	}
+	if len(ack) != 0 {
+		return fmt.Errorf("unexpected %d-byte acknowledgement", len(ack))
+	}
+	n.markSent(peer, incarnation, local.Next)
+	return nil
+}
+
+// sentTo returns the filter version last shipped to the given incarnation of a peer, or 0 if
+// nothing was shipped to that incarnation.
+func (n *Node) sentTo(peer string, incarnation uint64) uint64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if st := n.sent[peer]; st.incarnation == incarnation {
+		return st.version
+	}
+	return 0
+}
+
+// markSent records that the filter up to version has been shipped to the given incarnation of a
+// peer, replacing the state of any previous incarnation.
+func (n *Node) markSent(peer string, incarnation, version uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	st := n.sent[peer]
+	if st.incarnation != incarnation {
+		st = peerState{incarnation: incarnation}
+	}
+	if version > st.version {
+		st.version = version
+	}
+	n.sent[peer] = st
+}
+
+// timeout returns the deadline for a single exchange.
+func (n *Node) timeout() time.Duration {
+	if n.Timeout == 0 {
+		return DefaultTimeout
+	}
+	return n.Timeout
+}
+
+// reportError passes an error from a background exchange to OnError.
+func (n *Node) reportError(peer string, err error) {
+	if n.OnError != nil {
+		n.OnError(peer, err)
+	}
+}
+
+// writeHello writes the node's hello frame.
+func (n *Node) writeHello(w io.Writer) error {
+	payload := binary.BigEndian.AppendUint64(make([]byte, 0, 8+len(n.id)), n.incarnation)
+	return writeFrame(w, append(payload, n.id...))
+}
+
+// readHello reads a peer's hello frame, returning its ID and incarnation.
+func readHello(r io.Reader) (string, uint64, error) {
+	payload, err := readFrame(r)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(payload) < 8 {
+		return "", 0, errors.New("hello frame too short")
+	}
+	return string(payload[8:]), binary.BigEndian.Uint64(payload), nil
+}
+
+// writeDelta writes a delta as a single frame.
+func writeDelta(w io.Writer, d *sbf.Delta) error {
+	payload, err := d.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return writeFrame(w, payload)
+}
+
+// readDelta reads a delta frame.
+func readDelta(r io.Reader) (*sbf.Delta, error) {
+	payload, err := readFrame(r)
+	if err != nil {
+		return nil, err
+	}
+	var d sbf.Delta
+	if err := d.UnmarshalBinary(payload); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// writeFrame writes a length-prefixed frame.
+func writeFrame(w io.Writer, payload []byte) error {
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(payload)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	if len(payload) == 0 {
+		// A zero-length write can block on synchronous conns such as net.Pipe
+		return nil
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// readFrame reads a length-prefixed frame.
+func readFrame(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > maxFrameSize {
+		return nil, fmt.Errorf("frame of %d bytes exceeds limit", size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
@@ -0,0 +1,255 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Alfex4936/sbf-go"
+)
+
+func newFilter(t *testing.T) *sbf.StableBloomFilter {
+	t.Helper()
+	f, err := sbf.NewDefaultStableBloomFilter(10000, 0.01, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	t.Cleanup(f.StopDecay)
+	return f
+}
+
+func TestExchangeOverPipe(t *testing.T) {
+	a := NewNode("a", newFilter(t))
+	b := NewNode("b", newFilter(t))
+
+	a.filter.Add([]byte("from_a"))
+	b.filter.Add([]byte("from_b"))
+
+	connA, connB := net.Pipe()
+	defer connA.Close()
+	defer connB.Close()
+
+	errc := make(chan error, 1)
+	go func() { errc <- b.respond(connB) }()
+
+	if err := a.Exchange(connA); err != nil {
+		t.Fatalf("Exchange failed: %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("respond failed: %v", err)
+	}
+
+	for _, n := range []*Node{a, b} {
+		if !n.filter.Check([]byte("from_a")) || !n.filter.Check([]byte("from_b")) {
+			t.Errorf("Node %s is missing elements after exchange", n.ID())
+		}
+	}
+	if a.sentTo("b", b.incarnation) == 0 || b.sentTo("a", a.incarnation) == 0 {
+		t.Error("Expected both nodes to record the exchange")
+	}
+}
+
+func TestExchangeResendsUnacknowledgedDelta(t *testing.T) {
+	a := NewNode("a", newFilter(t))
+	b := NewNode("b", newFilter(t))
+	b.filter.Add([]byte("from_b"))
+
+	connA, connB := net.Pipe()
+	errc := make(chan error, 1)
+	go func() { errc <- b.respond(connB) }()
+
+	// Initiate an exchange by hand and hang up once the responder has written its delta
+	empty, err := a.filter.Delta(0)
+	if err != nil {
+		t.Fatalf("Delta failed: %v", err)
+	}
+	if err := a.writeHello(connA); err != nil {
+		t.Fatalf("writeHello failed: %v", err)
+	}
+	if _, _, err := readHello(connA); err != nil {
+		t.Fatalf("readHello failed: %v", err)
+	}
+	if err := writeDelta(connA, empty); err != nil {
+		t.Fatalf("writeDelta failed: %v", err)
+	}
+	if _, err := readDelta(connA); err != nil {
+		t.Fatalf("readDelta failed: %v", err)
+	}
+	connA.Close()
+
+	if err := <-errc; err == nil {
+		t.Error("Expected the responder to fail without an acknowledgement")
+	}
+	connB.Close()
+	if b.sentTo("a", a.incarnation) != 0 {
+		t.Fatal("Responder recorded an unacknowledged delta as delivered")
+	}
+
+	// The next exchange delivers the words again
+	connA, connB = net.Pipe()
+	defer connA.Close()
+	defer connB.Close()
+	go func() { errc <- b.respond(connB) }()
+	if err := a.Exchange(connA); err != nil {
+		t.Fatalf("Exchange failed: %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("respond failed: %v", err)
+	}
+	if !a.filter.Check([]byte("from_b")) {
+		t.Error("Initiator is missing the responder's element after the retry")
+	}
+	if b.sentTo("a", a.incarnation) == 0 {
+		t.Error("Expected the responder to record the acknowledged exchange")
+	}
+}
+
+func TestExchangeWithRestartedPeer(t *testing.T) {
+	a := NewNode("a", newFilter(t))
+	a.filter.Add([]byte("before_restart"))
+
+	exchange := func(b *Node) {
+		t.Helper()
+		connA, connB := net.Pipe()
+		defer connA.Close()
+		defer connB.Close()
+		errc := make(chan error, 1)
+		go func() { errc <- b.respond(connB) }()
+		if err := a.Exchange(connA); err != nil {
+			t.Fatalf("Exchange failed: %v", err)
+		}
+		if err := <-errc; err != nil {
+			t.Fatalf("respond failed: %v", err)
+		}
+	}
+
+	exchange(NewNode("b", newFilter(t)))
+
+	// b restarts with an empty filter under the same ID
+	restarted := NewNode("b", newFilter(t))
+	exchange(restarted)
+	if !restarted.filter.Check([]byte("before_restart")) {
+		t.Error("Restarted peer did not receive the words shipped to its previous incarnation")
+	}
+}
+
+func TestGossipConverges(t *testing.T) {
+	const numNodes = 4
+	const perNode = 200
+
+	nodes := make([]*Node, numNodes)
+	addrs := make([]string, numNodes)
+	for i := range nodes {
+		nodes[i] = NewNode(fmt.Sprintf("node%d", i), newFilter(t))
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Listen failed: %v", err)
+		}
+		defer ln.Close()
+		addrs[i] = ln.Addr().String()
+		go nodes[i].Serve(ln)
+	}
+
+	var logMu sync.Mutex
+	finished := false
+	defer func() {
+		logMu.Lock()
+		finished = true
+		logMu.Unlock()
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for i, n := range nodes {
+		peers := make([]string, 0, numNodes-1)
+		for j, addr := range addrs {
+			if j != i {
+				peers = append(peers, addr)
+			}
+		}
+		n.OnError = func(peer string, err error) {
+			logMu.Lock()
+			defer logMu.Unlock()
+			// Responders may still be failing on connections cut by shutdown after the test returns
+			if !finished {
+				t.Logf("exchange with %s failed: %v", peer, err)
+			}
+		}
+
+		wg.Add(1)
+		go func(n *Node) {
+			defer wg.Done()
+			n.Run(ctx, peers, 5*time.Millisecond)
+		}(n)
+	}
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	// Workers keep adding while they gossip
+	var adders sync.WaitGroup
+	for i, n := range nodes {
+		adders.Add(1)
+		go func(i int, n *Node) {
+			defer adders.Done()
+			for j := 0; j < perNode; j++ {
+				n.filter.Add([]byte(fmt.Sprintf("node%d_item%d", i, j)))
+				if j%20 == 0 {
+					time.Sleep(time.Millisecond)
+				}
+			}
+		}(i, n)
+	}
+	adders.Wait()
+
+	converged := func() bool {
+		for _, n := range nodes {
+			for i := range nodes {
+				for j := 0; j < perNode; j++ {
+					if !n.filter.Check([]byte(fmt.Sprintf("node%d_item%d", i, j))) {
+						return false
+					}
+				}
+			}
+		}
+		return true
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !converged() {
+		if time.Now().After(deadline) {
+			t.Fatal("Nodes did not converge")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestExchangeRejectsIncompatibleFilter(t *testing.T) {
+	small, err := sbf.NewStableBloomFilter(1024, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer small.StopDecay()
+
+	a := NewNode("a", newFilter(t))
+	b := NewNode("b", small)
+
+	connA, connB := net.Pipe()
+	defer connA.Close()
+
+	errc := make(chan error, 1)
+	go func() { errc <- a.Exchange(connA) }()
+
+	if err := b.respond(connB); err == nil {
+		t.Error("Expected the responder to reject an incompatible delta")
+	}
+	connB.Close() // As Serve does after a failed exchange
+	if err := <-errc; err == nil {
+		t.Error("Expected the initiator to fail when the responder hangs up")
+	}
+}
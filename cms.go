@@ -0,0 +1,228 @@
+package sbf
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// CountMinSketch is a decaying count-min sketch for estimating how often recent keys occurred.
+//
+// It holds depth rows of width counters, one hash function per row. Increment adds to the key's
+// counter in every row and Estimate returns the smallest of them, which never underestimates the
+// key's count. On every decay interval all counters are multiplied by the decay factor, so old
+// occurrences fade out exponentially; a factor of 0.5 halves the counts.
+//
+// It is safe for concurrent use by multiple goroutines.
+type CountMinSketch struct {
+	width        uint32
+	depth        uint32
+	hashFuncs    []Hash64
+	counts       []uint64 // Row-major counters, row i starting at i*width
+	decayFactor  float64
+	conservative atomic.Bool
+	decayRuns    atomic.Uint64
+	decayer      periodic
+}
+
+// NewCountMinSketch creates a new decaying count-min sketch.
+//
+// It uses default hash functions based on zeebo/xxh3. With width w and depth d, estimates exceed
+// the true count by at most e·N/w with probability 1 - e^-d, where N is the sum of all counts.
+//
+// Parameters:
+//   - width: Number of counters per row.
+//   - depth: Number of rows.
+//   - decayFactor: Factor applied to every counter on each decay interval (between 0 and 1). If zero, defaults to 0.5.
+//   - decayInterval: Time duration between decay operations. If zero, defaults to 1 minute.
+//
+// Returns:
+//   - A pointer to the CountMinSketch.
+//   - An error if the parameters are invalid.
+func NewCountMinSketch(width, depth uint32, decayFactor float64, decayInterval time.Duration) (*CountMinSketch, error) {
+	decayFactor, decayInterval, err := sketchParams(width, depth, decayFactor, decayInterval)
+	if err != nil {
+		return nil, err
+	}
+	c := newCountMinSketch(width, depth, decayFactor)
+	c.decayer.start(decayInterval, c.decay)
+	return c, nil
+}
+
+// sketchParams validates the parameters of a count-min sketch and applies the decay defaults.
+func sketchParams(width, depth uint32, decayFactor float64, decayInterval time.Duration) (float64, time.Duration, error) {
+	if width == 0 || depth == 0 {
+		return 0, 0, errors.New("width and depth must be greater than 0")
+	}
+	if decayFactor == 0 {
+		decayFactor = 0.5
+	}
+	if decayFactor < 0 || decayFactor > 1 {
+		return 0, 0, errors.New("decay factor must be between 0 and 1")
+	}
+	if decayInterval == 0 {
+		decayInterval = time.Minute
+	}
+	if decayInterval < 0 {
+		return 0, 0, errors.New("decay interval must not be negative")
+	}
+	return decayFactor, decayInterval, nil
+}
+
+// newCountMinSketch creates a count-min sketch without starting its decay process.
+func newCountMinSketch(width, depth uint32, decayFactor float64) *CountMinSketch {
+	hashFuncs, _ := defaultHashFuncs(depth)
+	return &CountMinSketch{
+		width:       width,
+		depth:       depth,
+		hashFuncs:   hashFuncs,
+		counts:      make([]uint64, int(width)*int(depth)),
+		decayFactor: decayFactor,
+	}
+}
+
+// NewDefaultCountMinSketch creates a new decaying count-min sketch sized for an error bound.
+//
+// Parameters:
+//   - epsilon: Estimates exceed the true count by at most epsilon times the sum of all counts (between 0 and 1).
+//   - delta: Probability that an estimate exceeds that bound (between 0 and 1).
+//   - decayFactor: Factor applied to every counter on each decay interval (between 0 and 1). If zero, defaults to 0.5.
+//   - decayInterval: Time duration between decay operations. If zero, defaults to 1 minute.
+//
+// Returns:
+//   - A pointer to the CountMinSketch.
+//   - An error if the parameters are invalid.
+func NewDefaultCountMinSketch(epsilon, delta float64, decayFactor float64, decayInterval time.Duration) (*CountMinSketch, error) {
+	if epsilon <= 0.0 || epsilon >= 1.0 {
+		return nil, errors.New("epsilon must be between 0 and 1 (exclusive)")
+	}
+	if delta <= 0.0 || delta >= 1.0 {
+		return nil, errors.New("delta must be between 0 and 1 (exclusive)")
+	}
+	width := uint32(math.Ceil(math.E / epsilon))
+	depth := uint32(math.Ceil(math.Log(1 / delta)))
+	return NewCountMinSketch(width, depth, decayFactor, decayInterval)
+}
+
+// SetConservativeUpdate enables or disables conservative update.
+//
+// With conservative update, Increment only raises the counters that are needed to lift the key's
+// estimate by n, which markedly reduces overestimation for skewed streams. Concurrent increments
+// of the same key may then be partly lost, so estimates are no longer strict upper bounds under
+// contention. It is disabled by default.
+func (c *CountMinSketch) SetConservativeUpdate(enabled bool) {
+	c.conservative.Store(enabled)
+}
+
+// Increment adds n to the count of a key.
+//
+// Counters saturate at the maximum uint64 value.
+//
+// Returns:
+//   - The key's estimated count after the increment.
+func (c *CountMinSketch) Increment(key []byte, n uint64) uint64 {
+	var idx [16]int
+	indexes := c.indexes(key, idx[:0])
+
+	if !c.conservative.Load() {
+		estimate := uint64(math.MaxUint64)
+		for _, i := range indexes {
+			estimate = min(estimate, atomicAddSaturating(&c.counts[i], n))
+		}
+		return estimate
+	}
+
+	// Raise every counter to at least the current estimate plus n
+	target := satAdd(c.estimate(indexes), n)
+	for _, i := range indexes {
+		atomicMax(&c.counts[i], target)
+	}
+	return target
+}
+
+// Estimate returns the estimated count of a key.
+//
+// The estimate never underestimates the decayed count of the key.
+func (c *CountMinSketch) Estimate(key []byte) uint64 {
+	var idx [16]int
+	return c.estimate(c.indexes(key, idx[:0]))
+}
+
+// StopDecay stops the decay process of the sketch.
+//
+// This function should be called when the sketch is no longer needed to clean up resources.
+func (c *CountMinSketch) StopDecay() {
+	c.decayer.stop()
+}
+
+// decay multiplies every counter by the decay factor.
+func (c *CountMinSketch) decay() {
+	for i := range c.counts {
+		for {
+			old := atomic.LoadUint64(&c.counts[i])
+			if old == 0 {
+				break
+			}
+			var next uint64
+			if c.decayFactor == 0.5 {
+				next = old >> 1
+			} else {
+				next = uint64(float64(old) * c.decayFactor)
+			}
+			if atomic.CompareAndSwapUint64(&c.counts[i], old, next) {
+				break
+			}
+		}
+	}
+	c.decayRuns.Add(1)
+}
+
+// indexes appends the counter index of the key in each row to idx.
+func (c *CountMinSketch) indexes(key []byte, idx []int) []int {
+	for row := uint32(0); row < c.depth; row++ {
+		col := c.hashFuncs[row](key) % uint64(c.width)
+		idx = append(idx, int(row)*int(c.width)+int(col))
+	}
+	return idx
+}
+
+// estimate returns the smallest of the given counters.
+func (c *CountMinSketch) estimate(indexes []int) uint64 {
+	estimate := uint64(math.MaxUint64)
+	for _, i := range indexes {
+		estimate = min(estimate, atomic.LoadUint64(&c.counts[i]))
+	}
+	return estimate
+}
+
+// atomicAddSaturating adds n to *addr, stopping at the maximum uint64 value, and returns the new value.
+func atomicAddSaturating(addr *uint64, n uint64) uint64 {
+	for {
+		old := atomic.LoadUint64(addr)
+		next := satAdd(old, n)
+		if next == old || atomic.CompareAndSwapUint64(addr, old, next) {
+			return next
+		}
+	}
+}
+
+// atomicMax raises *addr to at least v.
+func atomicMax(addr *uint64, v uint64) {
+	for {
+		old := atomic.LoadUint64(addr)
+		if old >= v || atomic.CompareAndSwapUint64(addr, old, v) {
+			return
+		}
+	}
+}
+
+// satAdd returns a+b, or the maximum uint64 value if the sum overflows.
+func satAdd(a, b uint64) uint64 {
+	sum, carry := bits.Add64(a, b, 0)
+	if carry != 0 {
+		return math.MaxUint64
+	}
+	return sum
+}
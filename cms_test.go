@@ -0,0 +1,128 @@
+package sbf
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCountMinSketchEstimate(t *testing.T) {
+	c, err := NewDefaultCountMinSketch(0.001, 0.01, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create CountMinSketch: %v", err)
+	}
+	defer c.StopDecay()
+
+	counts := make(map[string]uint64)
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("key_%d", i%200)
+		n := uint64(i%7 + 1)
+		c.Increment([]byte(key), n)
+		counts[key] += n
+	}
+
+	var total uint64
+	for _, n := range counts {
+		total += n
+	}
+	for key, want := range counts {
+		got := c.Estimate([]byte(key))
+		if got < want {
+			t.Errorf("Estimate(%s) = %d underestimates %d", key, got, want)
+		}
+		if got > want+total/100 {
+			t.Errorf("Estimate(%s) = %d overestimates %d by more than the bound", key, got, want)
+		}
+	}
+	if got := c.Estimate([]byte("absent")); got > total/100 {
+		t.Errorf("Estimate of an absent key is %d", got)
+	}
+}
+
+func TestCountMinSketchConservativeUpdate(t *testing.T) {
+	// A tiny sketch forces collisions, where conservative update overestimates less
+	plain, err := NewCountMinSketch(16, 3, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create CountMinSketch: %v", err)
+	}
+	defer plain.StopDecay()
+	conservative, err := NewCountMinSketch(16, 3, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create CountMinSketch: %v", err)
+	}
+	defer conservative.StopDecay()
+	conservative.SetConservativeUpdate(true)
+
+	for i := 0; i < 1000; i++ {
+		key := []byte(fmt.Sprintf("key_%d", i%50))
+		plain.Increment(key, 1)
+		if got := conservative.Increment(key, 1); got < uint64(i/50+1) {
+			t.Fatalf("Conservative Increment returned %d, below the true count %d", got, i/50+1)
+		}
+	}
+
+	var plainErr, conservativeErr uint64
+	for i := 0; i < 50; i++ {
+		key := []byte(fmt.Sprintf("key_%d", i))
+		if conservative.Estimate(key) < 20 {
+			t.Fatalf("Conservative estimate of %s underestimates", key)
+		}
+		plainErr += plain.Estimate(key) - 20
+		conservativeErr += conservative.Estimate(key) - 20
+	}
+	if conservativeErr >= plainErr {
+		t.Errorf("Expected conservative update to reduce error: %d vs %d", conservativeErr, plainErr)
+	}
+}
+
+func TestCountMinSketchDecay(t *testing.T) {
+	c, err := NewCountMinSketch(1024, 4, 0.5, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create CountMinSketch: %v", err)
+	}
+	defer c.StopDecay()
+
+	key := []byte("key")
+	c.Increment(key, 100)
+	c.decay()
+	if got := c.Estimate(key); got != 50 {
+		t.Errorf("Expected 50 after halving, got %d", got)
+	}
+
+	quarter, err := NewCountMinSketch(1024, 4, 0.25, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create CountMinSketch: %v", err)
+	}
+	defer quarter.StopDecay()
+	quarter.Increment(key, 100)
+	quarter.decay()
+	if got := quarter.Estimate(key); got != 25 {
+		t.Errorf("Expected 25 after decaying by 0.25, got %d", got)
+	}
+}
+
+func TestCountMinSketchSaturates(t *testing.T) {
+	c, err := NewCountMinSketch(64, 2, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create CountMinSketch: %v", err)
+	}
+	defer c.StopDecay()
+
+	key := []byte("key")
+	c.Increment(key, 1<<63)
+	if got := c.Increment(key, 1<<63+5); got != ^uint64(0) {
+		t.Errorf("Expected the counter to saturate, got %d", got)
+	}
+}
+
+func TestCountMinSketchInvalidParameters(t *testing.T) {
+	if _, err := NewCountMinSketch(0, 4, 0.5, time.Minute); err == nil {
+		t.Error("Expected an error for zero width")
+	}
+	if _, err := NewCountMinSketch(64, 4, 1.5, time.Minute); err == nil {
+		t.Error("Expected an error for a decay factor above 1")
+	}
+	if _, err := NewDefaultCountMinSketch(0, 0.01, 0.5, time.Minute); err == nil {
+		t.Error("Expected an error for zero epsilon")
+	}
+}
@@ -0,0 +1,187 @@
+package sbf
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Config describes the parameters of a Stable Bloom Filter in a form that round-trips through
+// JSON, YAML and similar configuration formats.
+//
+// The filter size is either given directly with Size, or derived from ExpectedItems and
+// FalsePositiveRate like NewDefaultStableBloomFilter does.
+type Config struct {
+	Size              uint32   `json:"size,omitempty" yaml:"size,omitempty"`                               // Size of the filter in bits
+	HashFunctions     uint32   `json:"hash_functions,omitempty" yaml:"hash_functions,omitempty"`           // Number of hash functions
+	ExpectedItems     uint32   `json:"expected_items,omitempty" yaml:"expected_items,omitempty"`           // Used when Size is zero
+	FalsePositiveRate float64  `json:"false_positive_rate,omitempty" yaml:"false_positive_rate,omitempty"` // Used when Size is zero
+	DecayRate         float64  `json:"decay_rate" yaml:"decay_rate"`                                       // Defaults to 0.01 if zero
+	DecayInterval     Duration `json:"decay_interval" yaml:"decay_interval"`                               // Defaults to 1 minute if zero
+	HashAlgorithm     string   `json:"hash_algorithm,omitempty" yaml:"hash_algorithm,omitempty"`           // Registered algorithm name, defaults to "xxh3-seeded"
+	Layout            string   `json:"layout,omitempty" yaml:"layout,omitempty"`                           // "standard" or "partitioned", defaults to "standard"
+}
+
+// Duration is a time.Duration that is encoded as a string such as "1m30s".
+type Duration time.Duration
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// NewStableBloomFilterFromConfig creates a new Stable Bloom Filter from a Config.
+//
+// It uses hash functions from the named HashAlgorithm with the seeds 0, 1, ..., or a random key for
+// keyed algorithms such as siphash. HashAlgorithm defaults to xxh3-seeded. If Size is zero, the size and number of hash functions are derived from
+// ExpectedItems and FalsePositiveRate; otherwise HashFunctions defaults to 7. A non-zero
+// HashFunctions always takes precedence. Layout selects LayoutStandard or LayoutPartitioned.
+//
+// Returns:
+//   - A pointer to the StableBloomFilter.
+//   - An error if the configuration is invalid.
+func NewStableBloomFilterFromConfig(cfg Config) (*StableBloomFilter, error) {
+	alg := HashXXH3Seeded
+	if cfg.HashAlgorithm != "" {
+		var err error
+		if alg, err = ParseHashAlgorithm(cfg.HashAlgorithm); err != nil {
+			return nil, err
+		}
+	}
+
+	layout, err := ParseLayout(cfg.Layout)
+	if err != nil {
+		return nil, err
+	}
+
+	m, k := cfg.Size, cfg.HashFunctions
+	if m == 0 {
+		var err error
+		if m, err = OptimalM(cfg.ExpectedItems, cfg.FalsePositiveRate); err != nil {
+			return nil, err
+		}
+		if k == 0 {
+			if k, err = OptimalK(m, cfg.ExpectedItems); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if k == 0 {
+		k = 7
+	}
+
+	decayRate := cfg.DecayRate
+	if decayRate < 0 || decayRate > 1 {
+		return nil, errors.New("decay rate must be between 0 and 1")
+	}
+	if decayRate == 0 {
+		decayRate = 0.01
+	}
+	decayInterval := time.Duration(cfg.DecayInterval)
+	if decayInterval < 0 {
+		return nil, errors.New("decay interval must not be negative")
+	}
+	if decayInterval == 0 {
+		decayInterval = time.Minute
+	}
+
+	return newStableBloomFilterWithHash(m, k, alg, nil, layout, decayRate, decayInterval)
+}
+
+// Config returns the parameters of the Stable Bloom Filter.
+//
+// Passing the result to NewStableBloomFilterFromConfig creates an empty filter with the same
+// parameters, except for the hash seeds. HashAlgorithm is empty for filters built with custom hash
+// functions.
+func (sbf *StableBloomFilter) Config() Config {
+	cfg := Config{
+		Size:          sbf.m,
+		HashFunctions: sbf.k,
+		DecayRate:     sbf.decayRate,
+		DecayInterval: Duration(sbf.decayPeriod),
+	}
+	if sbf.hashAlg != HashCustom {
+		cfg.HashAlgorithm = sbf.hashAlg.String()
+	}
+	if sbf.layout != LayoutStandard {
+		cfg.Layout = sbf.layout.String()
+	}
+	return cfg
+}
+
+// filterJSON is the JSON representation of a Stable Bloom Filter.
+type filterJSON struct {
+	Config
+	Seeds []uint64 `json:"seeds"`
+	Bits  []byte   `json:"bits"` // Raw little-endian words, base64-encoded
+}
+
+// MarshalJSON implements json.Marshaler.
+//
+// The filter is encoded as its Config fields plus the hash seeds and the bit array as base64 of
+// the raw word encoding described in FORMAT.md. This is intended for small filters and debug
+// endpoints; use MarshalBinary for large filters.
+//
+// Returns ErrCustomHash if the filter was built with custom hash functions.
+func (sbf *StableBloomFilter) MarshalJSON() ([]byte, error) {
+	if sbf.hashAlg == HashCustom {
+		return nil, ErrCustomHash
+	}
+	return json.Marshal(filterJSON{
+		Config: sbf.Config(),
+		Seeds:  sbf.seeds,
+		Bits:   appendRaw(make([]byte, 0, 8*sbf.numBuckets), sbf.snapshot()),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+//
+// The receiver must be a zero StableBloomFilter, such as a new variable. The decoded filter starts
+// its own decay process, so StopDecay must be called on it when it is no longer needed.
+func (sbf *StableBloomFilter) UnmarshalJSON(data []byte) error {
+	if sbf.filter != nil {
+		return ErrInitialized
+	}
+
+	var fj filterJSON
+	if err := json.Unmarshal(data, &fj); err != nil {
+		return err
+	}
+	alg, err := ParseHashAlgorithm(fj.HashAlgorithm)
+	if err != nil {
+		return err
+	}
+	layout, err := ParseLayout(fj.Layout)
+	if err != nil {
+		return err
+	}
+
+	h := &filterHeader{
+		encoding:      encodingRaw,
+		m:             fj.Size,
+		k:             fj.HashFunctions,
+		decayRate:     fj.DecayRate,
+		decayInterval: time.Duration(fj.DecayInterval),
+		hashAlg:       alg,
+		layout:        layout,
+		seeds:         fj.Seeds,
+	}
+	if err := h.validate(); err != nil {
+		return err
+	}
+	words, err := decodeRaw(fj.Bits, h.m/64)
+	if err != nil {
+		return err
+	}
+	return sbf.restore(h, words)
+}
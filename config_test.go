@@ -0,0 +1,155 @@
+package sbf
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestConfigJSONRoundTrip(t *testing.T) {
+	var cfg Config
+	if err := json.Unmarshal([]byte(`{"expected_items": 1000, "false_positive_rate": 0.01, "decay_rate": 0.05, "decay_interval": "30s"}`), &cfg); err != nil {
+		t.Fatalf("Failed to decode config: %v", err)
+	}
+	if time.Duration(cfg.DecayInterval) != 30*time.Second {
+		t.Errorf("Expected a 30s decay interval, got %v", time.Duration(cfg.DecayInterval))
+	}
+
+	sbf, err := NewStableBloomFilterFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewStableBloomFilterFromConfig failed: %v", err)
+	}
+	defer sbf.StopDecay()
+
+	wantM, _ := OptimalM(1000, 0.01)
+	wantK, _ := OptimalK(wantM, 1000)
+	if sbf.k != wantK || sbf.m < wantM || sbf.m%64 != 0 {
+		t.Errorf("Expected m>=%d k=%d, got m=%d k=%d", wantM, wantK, sbf.m, sbf.k)
+	}
+
+	encoded, err := json.Marshal(sbf.Config())
+	if err != nil {
+		t.Fatalf("Failed to encode config: %v", err)
+	}
+	want := fmt.Sprintf(`{"size":%d,"hash_functions":%d,"decay_rate":0.05,"decay_interval":"30s","hash_algorithm":"xxh3-seeded"}`, sbf.m, sbf.k)
+	if string(encoded) != want {
+		t.Errorf("Expected %s, got %s", want, encoded)
+	}
+
+	var decoded Config
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Failed to decode config: %v", err)
+	}
+	if decoded != sbf.Config() {
+		t.Errorf("Config changed after round trip: %+v vs %+v", decoded, sbf.Config())
+	}
+}
+
+func TestNewStableBloomFilterFromConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantM   uint32
+		wantK   uint32
+		wantErr bool
+	}{
+		{name: "explicit size", cfg: Config{Size: 1000}, wantM: 1024, wantK: 7},
+		{name: "explicit size and k", cfg: Config{Size: 2048, HashFunctions: 3}, wantM: 2048, wantK: 3},
+		{name: "no size", cfg: Config{}, wantErr: true},
+		{name: "bad decay rate", cfg: Config{Size: 1024, DecayRate: 2}, wantErr: true},
+		{name: "negative interval", cfg: Config{Size: 1024, DecayInterval: Duration(-time.Second)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sbf, err := NewStableBloomFilterFromConfig(tt.cfg)
+			if tt.wantErr {
+				if err == nil {
+					sbf.StopDecay()
+					t.Error("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			defer sbf.StopDecay()
+			if sbf.m != tt.wantM || sbf.k != tt.wantK {
+				t.Errorf("Expected m=%d k=%d, got m=%d k=%d", tt.wantM, tt.wantK, sbf.m, sbf.k)
+			}
+			if sbf.decayRate != 0.01 || sbf.decayPeriod != time.Minute {
+				t.Errorf("Expected default decay settings, got %f %v", sbf.decayRate, sbf.decayPeriod)
+			}
+		})
+	}
+}
+
+func TestFilterJSONRoundTrip(t *testing.T) {
+	sbf, err := NewStableBloomFilter(1024, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+	sbf.Add([]byte("element"))
+
+	encoded, err := json.Marshal(sbf)
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+
+	var restored StableBloomFilter
+	if err := json.Unmarshal(encoded, &restored); err != nil {
+		t.Fatalf("UnmarshalJSON failed: %v", err)
+	}
+	defer restored.StopDecay()
+
+	if !restored.Check([]byte("element")) {
+		t.Error("Element missing after round trip")
+	}
+	if restored.Config() != sbf.Config() {
+		t.Errorf("Config changed after round trip: %+v vs %+v", restored.Config(), sbf.Config())
+	}
+
+	if err := json.Unmarshal(encoded, &restored); !errors.Is(err, ErrInitialized) {
+		t.Errorf("Expected ErrInitialized decoding into a live filter, got %v", err)
+	}
+
+	var invalid StableBloomFilter
+	if err := json.Unmarshal([]byte(`{"size":1024,"hash_functions":7,"decay_interval":"1m","hash_algorithm":"md5","seeds":[0,1,2,3,4,5,6],"bits":""}`), &invalid); err == nil {
+		invalid.StopDecay()
+		t.Error("Expected an error for an unknown hash algorithm")
+	}
+}
+
+func TestFilterGobRoundTrip(t *testing.T) {
+	type checkpoint struct {
+		Name   string
+		Filter *StableBloomFilter
+	}
+
+	sbf, err := NewDefaultStableBloomFilter(1000, 0.01, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+	sbf.Add([]byte("element"))
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(checkpoint{Name: "dedup", Filter: sbf}); err != nil {
+		t.Fatalf("Gob encoding failed: %v", err)
+	}
+
+	var restored checkpoint
+	if err := gob.NewDecoder(&buf).Decode(&restored); err != nil {
+		t.Fatalf("Gob decoding failed: %v", err)
+	}
+	defer restored.Filter.StopDecay()
+
+	if restored.Name != "dedup" || !restored.Filter.Check([]byte("element")) {
+		t.Error("Filter state lost in gob round trip")
+	}
+}
@@ -0,0 +1,227 @@
+package sbf
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// CountingStableBloomFilter is a Stable Bloom Filter with small saturating counters instead of bits.
+//
+// Counters are packed into uint64 words. Add increments an element's k counters and Remove
+// decrements them, so elements can be retracted. Count returns the smallest of the k counters,
+// an upper bound on how often the element was added. Decay forgets elements by decrementing each
+// non-zero counter with probability decayRate.
+//
+// The filter supports concurrent access and can be safely used by multiple goroutines.
+type CountingStableBloomFilter struct {
+	m         uint32   // Number of counters
+	k         uint32   // Number of hash functions
+	width     uint32   // Bits per counter
+	max       uint64   // Largest counter value, at which counters saturate
+	perWord   uint32   // Counters per word
+	decayRate float64  // Probability of decrementing a counter during each decay pass
+	counters  []uint64 // Counters packed into words
+	hashFuncs []Hash64 // Slice of hash functions
+	decayRuns atomic.Uint64
+	decayer   periodic
+}
+
+// NewCountingStableBloomFilter creates a new Counting Stable Bloom Filter with the specified parameters.
+//
+// If hashFuncs is empty, it uses default hash functions based on zeebo/xxh3.
+//
+// Parameters:
+//   - m: Number of counters.
+//   - counterBits: Bits per counter: 2, 4, 8 or 16. If zero, defaults to 4.
+//   - hashFuncs: Slice of hash functions to use. If empty, default hash functions are used.
+//   - decayRate: Probability of decrementing each counter during each decay interval (between 0 and 1).
+//   - decayInterval: Time duration between decay operations.
+//
+// Returns:
+//   - A pointer to the CountingStableBloomFilter.
+//   - An error if the parameters are invalid.
+func NewCountingStableBloomFilter(m uint32, counterBits uint32, hashFuncs []Hash64, decayRate float64, decayInterval time.Duration) (*CountingStableBloomFilter, error) {
+	if m == 0 {
+		return nil, errors.New("filter size m must be greater than 0")
+	}
+	if counterBits == 0 {
+		counterBits = 4
+	}
+	switch counterBits {
+	case 2, 4, 8, 16:
+	default:
+		// 1-bit counters saturate on the first Add, so Remove could never decrement them
+		return nil, errors.New("counter width must be 2, 4, 8 or 16 bits")
+	}
+	if decayRate < 0 || decayRate > 1 {
+		return nil, errors.New("decay rate must be between 0 and 1")
+	}
+	if decayInterval <= 0 {
+		return nil, errors.New("decay interval must be positive")
+	}
+	if len(hashFuncs) == 0 {
+		hashFuncs, _ = defaultHashFuncs(7)
+	}
+
+	// Ensure m fills whole words
+	perWord := 64 / counterBits
+	if m%perWord != 0 {
+		m += perWord - (m % perWord)
+	}
+
+	c := &CountingStableBloomFilter{
+		m:         m,
+		k:         uint32(len(hashFuncs)),
+		width:     counterBits,
+		max:       1<<counterBits - 1,
+		perWord:   perWord,
+		decayRate: decayRate,
+		counters:  make([]uint64, m/perWord),
+		hashFuncs: hashFuncs,
+	}
+	c.decayer.start(decayInterval, c.decay)
+	return c, nil
+}
+
+// Add inserts an element into the filter, incrementing each of its counters up to the maximum.
+func (c *CountingStableBloomFilter) Add(data []byte) {
+	for i := uint32(0); i < c.k; i++ {
+		c.update(c.hashIndex(data, i), true)
+	}
+}
+
+// Remove retracts one insertion of an element.
+//
+// It only decrements the counters if every counter is non-zero, so removing an element that is
+// definitely absent has no effect. Saturated counters are never decremented, because their true
+// value is unknown. Removing an element that was not added, but is reported present because of a
+// false positive, can cause false negatives for other elements.
+//
+// Returns:
+//   - true if the element was present and at least one of its counters was decremented, or false
+//     if it was absent or all of its counters are saturated.
+func (c *CountingStableBloomFilter) Remove(data []byte) bool {
+	if !c.Check(data) {
+		return false
+	}
+	removed := false
+	for i := uint32(0); i < c.k; i++ {
+		if c.update(c.hashIndex(data, i), false) {
+			removed = true
+		}
+	}
+	return removed
+}
+
+// Check tests if an element might be in the filter.
+//
+// Returns true if the element might be in the filter, or false if the element is definitely not in the filter.
+func (c *CountingStableBloomFilter) Check(data []byte) bool {
+	return c.Count(data) > 0
+}
+
+// Count estimates how many times an element was added and not yet removed or decayed.
+//
+// It returns the smallest of the element's counters, which never underestimates the count of an
+// element that was not removed or decayed, and is capped at the counter's maximum value.
+func (c *CountingStableBloomFilter) Count(data []byte) uint64 {
+	count := c.max
+	for i := uint32(0); i < c.k && count > 0; i++ {
+		count = min(count, c.load(c.hashIndex(data, i)))
+	}
+	return count
+}
+
+// EstimateFalsePositiveRate estimates the current false positive rate of the filter.
+//
+// The estimation is based on the fraction of non-zero counters and the number of hash functions.
+func (c *CountingStableBloomFilter) EstimateFalsePositiveRate() float64 {
+	var nonZero uint64
+	for i := range c.counters {
+		word := atomic.LoadUint64(&c.counters[i])
+		for j := uint32(0); j < c.perWord; j++ {
+			if (word>>(j*c.width))&c.max != 0 {
+				nonZero++
+			}
+		}
+	}
+	return math.Pow(float64(nonZero)/float64(c.m), float64(c.k))
+}
+
+// StopDecay stops the decay process of the filter.
+//
+// This function should be called when the filter is no longer needed to clean up resources.
+func (c *CountingStableBloomFilter) StopDecay() {
+	c.decayer.stop()
+}
+
+// decay decrements each non-zero counter with probability decayRate.
+func (c *CountingStableBloomFilter) decay() {
+	randSrc := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for i := range c.counters {
+		// Decide which counters to decrement once, then apply the decision atomically
+		var dec uint64
+		word := atomic.LoadUint64(&c.counters[i])
+		for j := uint32(0); j < c.perWord; j++ {
+			if (word>>(j*c.width))&c.max != 0 && randSrc.Float64() < c.decayRate {
+				dec |= 1 << (j * c.width)
+			}
+		}
+		if dec == 0 {
+			continue
+		}
+		for {
+			old := atomic.LoadUint64(&c.counters[i])
+			next := old
+			for j := uint32(0); j < c.perWord; j++ {
+				shift := j * c.width
+				if dec&(1<<shift) != 0 && (old>>shift)&c.max != 0 {
+					next -= 1 << shift
+				}
+			}
+			if atomic.CompareAndSwapUint64(&c.counters[i], old, next) {
+				break
+			}
+		}
+	}
+	c.decayRuns.Add(1)
+}
+
+// update increments or decrements counter idx atomically. Increments stop at the maximum, and
+// saturated or zero counters are not decremented.
+//
+// Returns true if the counter changed.
+func (c *CountingStableBloomFilter) update(idx uint32, increment bool) bool {
+	addr := &c.counters[idx/c.perWord]
+	shift := idx % c.perWord * c.width
+	for {
+		old := atomic.LoadUint64(addr)
+		v := (old >> shift) & c.max
+		switch {
+		case increment && v < c.max:
+			v++
+		case !increment && v > 0 && v < c.max:
+			v--
+		default:
+			return false
+		}
+		next := old&^(c.max<<shift) | v<<shift
+		if atomic.CompareAndSwapUint64(addr, old, next) {
+			return true
+		}
+	}
+}
+
+// load returns the value of counter idx.
+func (c *CountingStableBloomFilter) load(idx uint32) uint64 {
+	word := atomic.LoadUint64(&c.counters[idx/c.perWord])
+	return (word >> (idx % c.perWord * c.width)) & c.max
+}
+
+// hashIndex computes the counter index for the i-th hash function.
+func (c *CountingStableBloomFilter) hashIndex(data []byte, i uint32) uint32 {
+	return uint32(c.hashFuncs[i](data) % uint64(c.m))
+}
@@ -0,0 +1,132 @@
+package sbf
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCountingStableBloomFilterAddRemove(t *testing.T) {
+	c, err := NewCountingStableBloomFilter(1<<14, 0, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create CountingStableBloomFilter: %v", err)
+	}
+	defer c.StopDecay()
+
+	data := []byte("event_1")
+	c.Add(data)
+	c.Add(data)
+	c.Add(data)
+	if got := c.Count(data); got != 3 {
+		t.Errorf("Expected count 3, got %d", got)
+	}
+
+	if !c.Remove(data) {
+		t.Error("Remove of a present element returned false")
+	}
+	if got := c.Count(data); got != 2 {
+		t.Errorf("Expected count 2 after Remove, got %d", got)
+	}
+	c.Remove(data)
+	c.Remove(data)
+	if c.Check(data) {
+		t.Error("Element still present after every insertion was removed")
+	}
+	if c.Remove(data) {
+		t.Error("Remove of an absent element returned true")
+	}
+}
+
+func TestCountingStableBloomFilterRemoveKeepsOthers(t *testing.T) {
+	c, err := NewCountingStableBloomFilter(1<<14, 4, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create CountingStableBloomFilter: %v", err)
+	}
+	defer c.StopDecay()
+
+	for i := 0; i < 500; i++ {
+		c.Add([]byte(fmt.Sprintf("element_%d", i)))
+	}
+	for i := 0; i < 500; i += 2 {
+		c.Remove([]byte(fmt.Sprintf("element_%d", i)))
+	}
+	for i := 1; i < 500; i += 2 {
+		if !c.Check([]byte(fmt.Sprintf("element_%d", i))) {
+			t.Fatalf("element_%d lost after removing other elements", i)
+		}
+	}
+}
+
+func TestCountingStableBloomFilterSaturation(t *testing.T) {
+	c, err := NewCountingStableBloomFilter(1024, 2, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create CountingStableBloomFilter: %v", err)
+	}
+	defer c.StopDecay()
+
+	data := []byte("hot")
+	for i := 0; i < 10; i++ {
+		c.Add(data)
+	}
+	if got := c.Count(data); got != 3 {
+		t.Errorf("Expected a 2-bit counter to saturate at 3, got %d", got)
+	}
+	// Saturated counters are sticky, since their true value is unknown
+	if c.Remove(data) {
+		t.Error("Remove returned true without decrementing any counter")
+	}
+	if got := c.Count(data); got != 3 {
+		t.Errorf("Expected a saturated counter to stay at 3, got %d", got)
+	}
+}
+
+func TestCountingStableBloomFilterDecay(t *testing.T) {
+	c, err := NewCountingStableBloomFilter(1<<12, 8, nil, 1.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create CountingStableBloomFilter: %v", err)
+	}
+	defer c.StopDecay()
+
+	data := []byte("element")
+	c.Add(data)
+	c.Add(data)
+	if fpr := c.EstimateFalsePositiveRate(); fpr <= 0 {
+		t.Errorf("Expected a positive FPR, got %f", fpr)
+	}
+
+	c.decay()
+	if got := c.Count(data); got != 1 {
+		t.Errorf("Expected one decrement per decay pass, got count %d", got)
+	}
+	c.decay()
+	if c.Check(data) {
+		t.Error("Element present after decaying to zero")
+	}
+	if fpr := c.EstimateFalsePositiveRate(); fpr != 0 {
+		t.Errorf("Expected FPR 0 after decay, got %f", fpr)
+	}
+}
+
+func TestCountingStableBloomFilterInvalidParameters(t *testing.T) {
+	tests := []struct {
+		name        string
+		m           uint32
+		counterBits uint32
+		decayRate   float64
+	}{
+		{name: "zero size", m: 0},
+		{name: "bad width", m: 1024, counterBits: 3},
+		{name: "1-bit width", m: 1024, counterBits: 1},
+		{name: "bad decay rate", m: 1024, decayRate: 1.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewCountingStableBloomFilter(tt.m, tt.counterBits, nil, tt.decayRate, time.Minute)
+			if err == nil {
+				c.StopDecay()
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
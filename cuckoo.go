@@ -0,0 +1,265 @@
+package sbf
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zeebo/xxh3"
+)
+
+// Cuckoo filter layout: a bucket of four 16-bit slots per uint64 word. A slot holds a 14-bit
+// fingerprint and a 2-bit age; an all-zero slot is empty.
+const (
+	cuckooSlots       = 4
+	cuckooSlotBits    = 16
+	cuckooSlotMask    = 1<<cuckooSlotBits - 1
+	cuckooFPBits      = 14
+	cuckooFPMask      = 1<<cuckooFPBits - 1
+	cuckooMaxAge      = 1<<(cuckooSlotBits-cuckooFPBits) - 1
+	cuckooMaxKicks    = 500
+	cuckooLoadFactor  = 0.95 // Target load used to size the table
+	cuckooAltHashMult = 0x5bd1e995
+)
+
+// CuckooFilter is a cuckoo filter whose entries expire when they are not refreshed.
+//
+// Each element is stored as a 14-bit fingerprint in one of two candidate buckets. Unlike a Bloom
+// filter it supports Remove, and it has a lower false positive rate at small sizes. Instead of
+// decaying bits, every aging tick increments the age of each entry and evicts entries that reach
+// the maximum age; adding an element that is already present resets its age. An element is
+// therefore remembered for between maxAge-1 and maxAge aging intervals after it was last added.
+//
+// When the table is full, Add evicts the entry it could not relocate, so the filter degrades by
+// forgetting elements rather than by rejecting them. It has the same Add and Check methods as
+// StableBloomFilter, and is safe for concurrent use by multiple goroutines.
+type CuckooFilter struct {
+	mu         sync.RWMutex
+	buckets    []uint64 // Four slots per bucket
+	mask       uint64   // Number of buckets minus one
+	maxAge     uint64
+	count      uint64 // Occupied slots
+	randSrc    *rand.Rand
+	evictions  atomic.Uint64 // Entries dropped because the table was full
+	agingRuns  atomic.Uint64
+	agingTimer periodic
+}
+
+// NewCuckooFilter creates a new Cuckoo Filter with aging.
+//
+// Parameters:
+//   - capacity: Number of elements the filter should hold.
+//   - maxAge: Number of aging intervals after which an element that was not added again is evicted (1 to 3).
+//   - agingInterval: Time duration between aging operations.
+//
+// Returns:
+//   - A pointer to the CuckooFilter.
+//   - An error if the parameters are invalid.
+func NewCuckooFilter(capacity uint32, maxAge uint8, agingInterval time.Duration) (*CuckooFilter, error) {
+	if capacity == 0 {
+		return nil, errors.New("capacity must be greater than 0")
+	}
+	if maxAge == 0 || maxAge > cuckooMaxAge {
+		return nil, errors.New("max age must be between 1 and 3")
+	}
+	if agingInterval <= 0 {
+		return nil, errors.New("aging interval must be positive")
+	}
+
+	numBuckets := uint64(math.Ceil(float64(capacity) / cuckooSlots / cuckooLoadFactor))
+	numBuckets = 1 << bits.Len64(numBuckets-1) // Round up to a power of two for the XOR trick
+	if numBuckets > 1<<32 {
+		return nil, errors.New("capacity is too large")
+	}
+
+	c := &CuckooFilter{
+		buckets: make([]uint64, numBuckets),
+		mask:    numBuckets - 1,
+		maxAge:  uint64(maxAge),
+		randSrc: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	c.agingTimer.start(agingInterval, c.age)
+	return c, nil
+}
+
+// Add inserts an element into the filter, or resets its age if it is already present.
+func (c *CuckooFilter) Add(data []byte) {
+	fp, i1, i2 := c.locate(data)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.refresh(i1, fp) || c.refresh(i2, fp) {
+		return
+	}
+	if c.insertEntry(i1, fp) || c.insertEntry(i2, fp) {
+		c.count++
+		return
+	}
+
+	// Relocate entries until one finds a free slot
+	i := i1
+	if c.randSrc.Intn(2) == 1 {
+		i = i2
+	}
+	entry := fp
+	for n := 0; n < cuckooMaxKicks; n++ {
+		slot := uint(c.randSrc.Intn(cuckooSlots)) * cuckooSlotBits
+		victim := (c.buckets[i] >> slot) & cuckooSlotMask
+		c.buckets[i] = c.buckets[i]&^(cuckooSlotMask<<slot) | entry<<slot
+		entry = victim
+
+		i = c.altIndex(i, entry&cuckooFPMask)
+		if c.insertEntry(i, entry) {
+			c.count++
+			return
+		}
+	}
+	// The table is full: the entry still displaced is forgotten
+	c.evictions.Add(1)
+}
+
+// Check tests if an element might be in the filter.
+//
+// Returns true if the element might be in the filter, or false if the element is definitely not in the filter.
+func (c *CuckooFilter) Check(data []byte) bool {
+	fp, i1, i2 := c.locate(data)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.find(i1, fp) >= 0 || c.find(i2, fp) >= 0
+}
+
+// Remove deletes an element from the filter.
+//
+// Only remove elements that were added: removing an element that is reported present because of
+// a false positive deletes another element that shares its fingerprint.
+//
+// Returns:
+//   - true if a matching entry was found and removed.
+func (c *CuckooFilter) Remove(data []byte) bool {
+	fp, i1, i2 := c.locate(data)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, i := range [2]uint64{i1, i2} {
+		if slot := c.find(i, fp); slot >= 0 {
+			c.buckets[i] &^= cuckooSlotMask << (uint(slot) * cuckooSlotBits)
+			c.count--
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns the number of entries in the filter.
+func (c *CuckooFilter) Count() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.count
+}
+
+// Evictions returns the number of entries dropped because the table was full.
+func (c *CuckooFilter) Evictions() uint64 {
+	return c.evictions.Load()
+}
+
+// EstimateFalsePositiveRate estimates the current false positive rate of the Cuckoo Filter.
+//
+// A lookup compares the fingerprint against the occupied slots of two buckets, so the estimate is
+// 1 - (1 - 2^-f)^(2·b·load) for f fingerprint bits, b slots per bucket and the current load factor.
+func (c *CuckooFilter) EstimateFalsePositiveRate() float64 {
+	c.mu.RLock()
+	load := float64(c.count) / float64(len(c.buckets)*cuckooSlots)
+	c.mu.RUnlock()
+	// Fingerprints are never zero, so there are 2^f - 1 possible values
+	return 1 - math.Pow(1-1/float64(cuckooFPMask), 2*cuckooSlots*load)
+}
+
+// StopDecay stops the aging process of the Cuckoo Filter.
+//
+// This function should be called when the filter is no longer needed to clean up resources.
+func (c *CuckooFilter) StopDecay() {
+	c.agingTimer.stop()
+}
+
+// age increments the age of every entry and evicts entries that reach the maximum age.
+func (c *CuckooFilter) age() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, bucket := range c.buckets {
+		if bucket == 0 {
+			continue
+		}
+		for s := uint(0); s < cuckooSlots; s++ {
+			shift := s * cuckooSlotBits
+			entry := (bucket >> shift) & cuckooSlotMask
+			if entry == 0 {
+				continue
+			}
+			age := entry>>cuckooFPBits + 1
+			if age >= c.maxAge {
+				bucket &^= cuckooSlotMask << shift
+				c.count--
+				continue
+			}
+			bucket = bucket&^(cuckooSlotMask<<shift) | (entry&cuckooFPMask|age<<cuckooFPBits)<<shift
+		}
+		c.buckets[i] = bucket
+	}
+	c.agingRuns.Add(1)
+}
+
+// locate returns the fingerprint and the two candidate buckets of an element.
+func (c *CuckooFilter) locate(data []byte) (fp, i1, i2 uint64) {
+	h := xxh3.Hash(data)
+	fp = (h >> 32) & cuckooFPMask
+	if fp == 0 {
+		fp = 1
+	}
+	i1 = h & c.mask
+	return fp, i1, c.altIndex(i1, fp)
+}
+
+// altIndex returns the other candidate bucket of a fingerprint stored in bucket i.
+func (c *CuckooFilter) altIndex(i, fp uint64) uint64 {
+	return (i ^ fp*cuckooAltHashMult) & c.mask
+}
+
+// find returns the slot of bucket i holding fingerprint fp, or -1.
+func (c *CuckooFilter) find(i, fp uint64) int {
+	bucket := c.buckets[i]
+	for s := 0; s < cuckooSlots; s++ {
+		if (bucket>>(uint(s)*cuckooSlotBits))&cuckooFPMask == fp {
+			return s
+		}
+	}
+	return -1
+}
+
+// refresh resets the age of fingerprint fp in bucket i, reporting whether it was found.
+func (c *CuckooFilter) refresh(i, fp uint64) bool {
+	s := c.find(i, fp)
+	if s < 0 {
+		return false
+	}
+	shift := uint(s) * cuckooSlotBits
+	c.buckets[i] = c.buckets[i]&^(cuckooSlotMask<<shift) | fp<<shift
+	return true
+}
+
+// insertEntry stores an entry, fingerprint and age, in a free slot of bucket i.
+func (c *CuckooFilter) insertEntry(i, entry uint64) bool {
+	bucket := c.buckets[i]
+	for s := uint(0); s < cuckooSlots; s++ {
+		shift := s * cuckooSlotBits
+		if (bucket>>shift)&cuckooSlotMask == 0 {
+			c.buckets[i] = bucket | entry<<shift
+			return true
+		}
+	}
+	return false
+}
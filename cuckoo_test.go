@@ -0,0 +1,177 @@
+package sbf
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCuckooFilterAddRemove(t *testing.T) {
+	c, err := NewCuckooFilter(1000, 3, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create CuckooFilter: %v", err)
+	}
+	defer c.StopDecay()
+
+	for i := 0; i < 1000; i++ {
+		c.Add([]byte(fmt.Sprintf("session_%d", i)))
+	}
+	for i := 0; i < 1000; i++ {
+		if !c.Check([]byte(fmt.Sprintf("session_%d", i))) {
+			t.Fatalf("session_%d should be present", i)
+		}
+	}
+	if got := c.Count(); got != 1000 {
+		t.Errorf("Expected 1000 entries, got %d", got)
+	}
+
+	for i := 0; i < 1000; i += 2 {
+		if !c.Remove([]byte(fmt.Sprintf("session_%d", i))) {
+			t.Fatalf("Remove of session_%d returned false", i)
+		}
+	}
+	for i := 1; i < 1000; i += 2 {
+		if !c.Check([]byte(fmt.Sprintf("session_%d", i))) {
+			t.Fatalf("session_%d lost after removing other elements", i)
+		}
+	}
+	if got := c.Count(); got != 500 {
+		t.Errorf("Expected 500 entries after removals, got %d", got)
+	}
+}
+
+func TestCuckooFilterReAddDoesNotDuplicate(t *testing.T) {
+	c, err := NewCuckooFilter(100, 3, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create CuckooFilter: %v", err)
+	}
+	defer c.StopDecay()
+
+	data := []byte("token")
+	c.Add(data)
+	c.Add(data)
+	if got := c.Count(); got != 1 {
+		t.Errorf("Expected 1 entry after adding twice, got %d", got)
+	}
+	c.Remove(data)
+	if c.Check(data) {
+		t.Error("Element still present after Remove")
+	}
+}
+
+func TestCuckooFilterAging(t *testing.T) {
+	c, err := NewCuckooFilter(100, 2, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create CuckooFilter: %v", err)
+	}
+	defer c.StopDecay()
+
+	old, fresh := []byte("old"), []byte("fresh")
+	c.Add(old)
+	c.Add(fresh)
+	c.age()
+	if !c.Check(old) {
+		t.Error("Element evicted before reaching its maximum age")
+	}
+
+	// Adding again resets the age
+	c.Add(fresh)
+	c.age()
+	if c.Check(old) {
+		t.Error("Element not evicted after reaching its maximum age")
+	}
+	if !c.Check(fresh) {
+		t.Error("Refreshed element was evicted")
+	}
+	if got := c.Count(); got != 1 {
+		t.Errorf("Expected 1 entry after aging, got %d", got)
+	}
+}
+
+func TestCuckooFilterAgingTick(t *testing.T) {
+	c, err := NewCuckooFilter(100, 1, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to create CuckooFilter: %v", err)
+	}
+	defer c.StopDecay()
+
+	data := []byte("ephemeral")
+	c.Add(data)
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Check(data) {
+		if time.Now().After(deadline) {
+			t.Fatal("Element not evicted by the aging process")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if c.agingRuns.Load() == 0 {
+		t.Error("Expected at least one aging run")
+	}
+}
+
+func TestCuckooFilterFull(t *testing.T) {
+	c, err := NewCuckooFilter(64, 3, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create CuckooFilter: %v", err)
+	}
+	defer c.StopDecay()
+
+	slots := uint64(len(c.buckets) * cuckooSlots)
+	for i := 0; i < 1000; i++ {
+		c.Add([]byte(fmt.Sprintf("element_%d", i)))
+	}
+	if got := c.Count(); got > slots {
+		t.Errorf("Count %d exceeds the %d slots", got, slots)
+	}
+	if c.Evictions() == 0 {
+		t.Error("Expected evictions once the table is full")
+	}
+	if fpr := c.EstimateFalsePositiveRate(); fpr <= 0 || fpr > 0.01 {
+		t.Errorf("Unexpected false positive rate estimate %f for a full table", fpr)
+	}
+}
+
+func TestCuckooFilterFalsePositiveRate(t *testing.T) {
+	c, err := NewCuckooFilter(10000, 3, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create CuckooFilter: %v", err)
+	}
+	defer c.StopDecay()
+
+	for i := 0; i < 10000; i++ {
+		c.Add([]byte(fmt.Sprintf("element_%d", i)))
+	}
+	falsePositives := 0
+	const trials = 100000
+	for i := 0; i < trials; i++ {
+		if c.Check([]byte(fmt.Sprintf("absent_%d", i))) {
+			falsePositives++
+		}
+	}
+	observed := float64(falsePositives) / trials
+	estimated := c.EstimateFalsePositiveRate()
+	if observed > 2*estimated+0.0005 {
+		t.Errorf("Observed false positive rate %f far above estimate %f", observed, estimated)
+	}
+}
+
+func TestCuckooFilterInvalidParams(t *testing.T) {
+	tests := []struct {
+		name     string
+		capacity uint32
+		maxAge   uint8
+		interval time.Duration
+	}{
+		{"zero capacity", 0, 3, time.Minute},
+		{"zero max age", 100, 0, time.Minute},
+		{"max age too large", 100, 4, time.Minute},
+		{"zero interval", 100, 3, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewCuckooFilter(tt.capacity, tt.maxAge, tt.interval); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
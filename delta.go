@@ -0,0 +1,259 @@
+package sbf
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// deltaFormatVersion is the version byte at the start of an encoded Delta.
+const deltaFormatVersion = 2
+
+// deltaHeaderSize is the size of an encoded Delta before its word count.
+const deltaHeaderSize = 35
+
+// ErrTrackingDisabled is returned by Delta when EnableDeltaTracking has not been called.
+var ErrTrackingDisabled = errors.New("delta tracking is not enabled")
+
+// Delta is a set of filter words that gained bits between two versions of a Stable Bloom Filter.
+//
+// Applying a Delta ORs its words into another replica, so deltas are idempotent and can be applied
+// in any order. Bits cleared by decay are not part of a Delta; each replica decays independently.
+type Delta struct {
+	M        uint32        // Size of the source filter in bits
+	K        uint32        // Number of hash functions of the source filter
+	Layout   Layout        // Bit layout of the source filter
+	HashAlg  HashAlgorithm // Hash algorithm of the source filter
+	SeedHash uint64        // Fingerprint of the source filter's hash seeds, which does not reveal keys
+	Since    uint64        // Version the delta was requested from
+	Next     uint64        // Version to request the following delta from
+	Indexes  []uint32      // Indexes of the changed words, in increasing order
+	Words    []uint64      // Values of the changed words
+}
+
+// EnableDeltaTracking starts recording which words of the filter gain bits, so that Delta can
+// produce incremental diffs. Words already holding bits are treated as changed at the first version.
+//
+// Tracking costs one uint64 of memory per 64 bits of filter and an extra read on each Add.
+// Calling it more than once has no effect.
+func (sbf *StableBloomFilter) EnableDeltaTracking() {
+	sbf.listenersMu.Lock()
+	defer sbf.listenersMu.Unlock()
+
+	if sbf.tracking.Load() {
+		return
+	}
+	sbf.version.Store(1)
+	sbf.versions = make([]uint64, sbf.numBuckets)
+	for i := range sbf.filter {
+		if atomic.LoadUint64(&sbf.filter[i]) != 0 {
+			sbf.versions[i] = 1
+		}
+	}
+	sbf.tracking.Store(true)
+}
+
+// Delta returns the words that gained bits since the given version.
+//
+// A since of 0 returns every non-empty word, which is a full snapshot. Pass the returned Delta's
+// Next as since on the following call to the same peer. A word that gains bits while Delta runs
+// may appear in both this delta and the next one; otherwise each change is delivered once.
+//
+// Returns:
+//   - The delta.
+//   - ErrTrackingDisabled if EnableDeltaTracking has not been called.
+func (sbf *StableBloomFilter) Delta(since uint64) (*Delta, error) {
+	if !sbf.tracking.Load() {
+		return nil, ErrTrackingDisabled
+	}
+
+	// Words stamped from here on carry the new version and belong to the next delta
+	next := sbf.version.Add(1)
+
+	d := &Delta{
+		M:        sbf.m,
+		K:        sbf.k,
+		Layout:   sbf.layout,
+		HashAlg:  sbf.hashAlg,
+		SeedHash: seedFingerprint(sbf.seeds),
+		Since:    since,
+		Next:     next,
+	}
+	for i := range sbf.filter {
+		if atomic.LoadUint64(&sbf.versions[i]) < since {
+			continue
+		}
+		if word := atomic.LoadUint64(&sbf.filter[i]); word != 0 {
+			d.Indexes = append(d.Indexes, uint32(i))
+			d.Words = append(d.Words, word)
+		}
+	}
+	return d, nil
+}
+
+// ApplyDelta ORs the words of a delta from another replica into the Stable Bloom Filter.
+//
+// Words that gain bits are recorded as changed, so they are forwarded in this filter's own deltas.
+// Filters with custom hash functions cannot be told apart, so deltas between them are accepted
+// as long as the size and layout match.
+//
+// Returns:
+//   - An *IncompatibleError if the delta comes from a filter with a different size, hash count,
+//     layout, hash algorithm or seeds.
+//   - An error if the delta is malformed.
+func (sbf *StableBloomFilter) ApplyDelta(d *Delta) error {
+	if d.M != sbf.m {
+		return &IncompatibleError{Param: "m", This: fmt.Sprint(sbf.m), Other: fmt.Sprint(d.M)}
+	}
+	if d.K != sbf.k {
+		return &IncompatibleError{Param: "k", This: fmt.Sprint(sbf.k), Other: fmt.Sprint(d.K)}
+	}
+	if d.Layout != sbf.layout {
+		return &IncompatibleError{Param: "layout", This: sbf.layout.String(), Other: d.Layout.String()}
+	}
+	if seedHash := seedFingerprint(sbf.seeds); d.HashAlg != sbf.hashAlg || d.SeedHash != seedHash {
+		return &IncompatibleError{
+			Param: "hash",
+			This:  describeSeedHash(sbf.hashAlg, seedHash),
+			Other: describeSeedHash(d.HashAlg, d.SeedHash),
+		}
+	}
+	if len(d.Indexes) != len(d.Words) {
+		return errors.New("delta has mismatched indexes and words")
+	}
+	for _, idx := range d.Indexes {
+		if idx >= sbf.numBuckets {
+			return fmt.Errorf("delta word index %d out of range", idx)
+		}
+	}
+
+	for i, idx := range d.Indexes {
+		if atomicOr(&sbf.filter[idx], d.Words[i]) {
+			sbf.markChanged(idx)
+		}
+	}
+	return nil
+}
+
+// Version returns the filter's current change version, or 0 if delta tracking is not enabled.
+func (sbf *StableBloomFilter) Version() uint64 {
+	return sbf.version.Load()
+}
+
+// markChanged records that a word gained bits at the current version.
+//
+// A Delta that advances the version between the load and the store may already have scanned the
+// word, so the stamp is rewritten until the version is unchanged after the store. This keeps every
+// stamp written after a Delta's scan at or above that Delta's Next.
+func (sbf *StableBloomFilter) markChanged(bucketIdx uint32) {
+	if !sbf.tracking.Load() {
+		return
+	}
+	for {
+		v := sbf.version.Load()
+		atomic.StoreUint64(&sbf.versions[bucketIdx], v)
+		if sbf.version.Load() == v {
+			return
+		}
+	}
+}
+
+// seedFingerprint returns a one-way 64-bit fingerprint of hash seeds, so replicas can compare keys
+// without sending them.
+func seedFingerprint(seeds []uint64) uint64 {
+	h := sha256.New()
+	var b [8]byte
+	for _, seed := range seeds {
+		binary.LittleEndian.PutUint64(b[:], seed)
+		h.Write(b[:])
+	}
+	return binary.LittleEndian.Uint64(h.Sum(nil))
+}
+
+// describeSeedHash returns a short description of a hash algorithm and seed fingerprint for error messages.
+func describeSeedHash(alg HashAlgorithm, seedHash uint64) string {
+	if alg == HashCustom {
+		return "custom"
+	}
+	return fmt.Sprintf("%s seeds #%016x", alg, seedHash)
+}
+
+// MarshalBinary encodes the delta compactly, with word indexes stored as varint gaps.
+func (d *Delta) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 0, deltaHeaderSize+binary.MaxVarintLen64+len(d.Words)*10)
+	buf = append(buf, deltaFormatVersion)
+	buf = binary.LittleEndian.AppendUint32(buf, d.M)
+	buf = binary.LittleEndian.AppendUint32(buf, d.K)
+	buf = append(buf, byte(d.Layout), byte(d.HashAlg))
+	buf = binary.LittleEndian.AppendUint64(buf, d.SeedHash)
+	buf = binary.LittleEndian.AppendUint64(buf, d.Since)
+	buf = binary.LittleEndian.AppendUint64(buf, d.Next)
+	buf = binary.AppendUvarint(buf, uint64(len(d.Words)))
+
+	var prev uint32
+	for i, idx := range d.Indexes {
+		if i > 0 && idx <= prev {
+			return nil, errors.New("delta indexes must be strictly increasing")
+		}
+		buf = binary.AppendUvarint(buf, uint64(idx-prev))
+		buf = binary.LittleEndian.AppendUint64(buf, d.Words[i])
+		prev = idx
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a delta produced by MarshalBinary.
+func (d *Delta) UnmarshalBinary(data []byte) error {
+	if len(data) < deltaHeaderSize || data[0] != deltaFormatVersion {
+		return errors.New("invalid delta encoding")
+	}
+	m := binary.LittleEndian.Uint32(data[1:])
+	k := binary.LittleEndian.Uint32(data[5:])
+	layout := Layout(data[9])
+	hashAlg := HashAlgorithm(data[10])
+	seedHash := binary.LittleEndian.Uint64(data[11:])
+	since := binary.LittleEndian.Uint64(data[19:])
+	next := binary.LittleEndian.Uint64(data[27:])
+	data = data[deltaHeaderSize:]
+
+	count, n := binary.Uvarint(data)
+	if n <= 0 || count > uint64(len(data)) {
+		return errors.New("invalid delta word count")
+	}
+	data = data[n:]
+
+	indexes := make([]uint32, 0, count)
+	words := make([]uint64, 0, count)
+	var idx uint64
+	for i := uint64(0); i < count; i++ {
+		gap, n := binary.Uvarint(data)
+		if n <= 0 || len(data) < n+8 {
+			return errors.New("truncated delta")
+		}
+		idx += gap
+		if idx >= uint64(m)/64 {
+			return fmt.Errorf("delta word index %d out of range", idx)
+		}
+		indexes = append(indexes, uint32(idx))
+		words = append(words, binary.LittleEndian.Uint64(data[n:]))
+		data = data[n+8:]
+	}
+	if len(data) != 0 {
+		return errors.New("trailing data after delta")
+	}
+
+	*d = Delta{
+		M:        m,
+		K:        k,
+		Layout:   layout,
+		HashAlg:  hashAlg,
+		SeedHash: seedHash,
+		Since:    since,
+		Next:     next,
+		Indexes:  indexes,
+		Words:    words,
+	}
+	return nil
+}
@@ -0,0 +1,291 @@
+package sbf
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDeltaRequiresTracking(t *testing.T) {
+	sbf, err := NewStableBloomFilter(1024, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+
+	if _, err := sbf.Delta(0); !errors.Is(err, ErrTrackingDisabled) {
+		t.Errorf("Expected ErrTrackingDisabled, got %v", err)
+	}
+}
+
+func TestDeltaIncremental(t *testing.T) {
+	sbf, err := NewStableBloomFilter(64*1024, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+
+	sbf.Add([]byte("before_tracking"))
+	sbf.EnableDeltaTracking()
+
+	full, err := sbf.Delta(0)
+	if err != nil {
+		t.Fatalf("Delta failed: %v", err)
+	}
+	if len(full.Words) == 0 || len(full.Words) > int(sbf.k) {
+		t.Errorf("Expected the full delta to hold the existing words, got %d", len(full.Words))
+	}
+
+	sbf.Add([]byte("after_tracking"))
+	d, err := sbf.Delta(full.Next)
+	if err != nil {
+		t.Fatalf("Delta failed: %v", err)
+	}
+	if len(d.Words) == 0 || len(d.Words) > int(sbf.k) {
+		t.Errorf("Expected only recently changed words, got %d", len(d.Words))
+	}
+
+	more, err := sbf.Delta(d.Next)
+	if err != nil {
+		t.Fatalf("Delta failed: %v", err)
+	}
+	if len(more.Words) != 0 {
+		t.Errorf("Expected an empty delta without changes, got %d words", len(more.Words))
+	}
+}
+
+func TestDeltaSendsEachChangeOnce(t *testing.T) {
+	sbf, err := NewStableBloomFilter(64*1024, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+	sbf.EnableDeltaTracking()
+
+	since := uint64(0)
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 100; i++ {
+			sbf.Add([]byte(fmt.Sprintf("round%d_element%d", round, i)))
+		}
+		d, err := sbf.Delta(since)
+		if err != nil {
+			t.Fatalf("Delta failed: %v", err)
+		}
+		if len(d.Words) == 0 {
+			t.Fatalf("Round %d: expected changed words", round)
+		}
+		again, err := sbf.Delta(d.Next)
+		if err != nil {
+			t.Fatalf("Delta failed: %v", err)
+		}
+		if len(again.Words) != 0 {
+			t.Errorf("Round %d: expected no words to be resent, got %d", round, len(again.Words))
+		}
+		since = again.Next
+	}
+}
+
+func TestDeltaReplicasConverge(t *testing.T) {
+	const numPeers = 3
+	peers := make([]*StableBloomFilter, numPeers)
+	for i := range peers {
+		p, err := NewDefaultStableBloomFilter(10000, 0.01, 0.0, time.Hour)
+		if err != nil {
+			t.Fatalf("Failed to create StableBloomFilter: %v", err)
+		}
+		defer p.StopDecay()
+		p.EnableDeltaTracking()
+		peers[i] = p
+	}
+
+	// since[i][j] is the version of peer i last shipped to peer j
+	since := make([][]uint64, numPeers)
+	for i := range since {
+		since[i] = make([]uint64, numPeers)
+	}
+	ship := func(from, to int) {
+		d, err := peers[from].Delta(since[from][to])
+		if err != nil {
+			t.Fatalf("Delta failed: %v", err)
+		}
+		encoded, err := d.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary failed: %v", err)
+		}
+		var decoded Delta
+		if err := decoded.UnmarshalBinary(encoded); err != nil {
+			t.Fatalf("UnmarshalBinary failed: %v", err)
+		}
+		if err := peers[to].ApplyDelta(&decoded); err != nil {
+			t.Fatalf("ApplyDelta failed: %v", err)
+		}
+		since[from][to] = d.Next
+	}
+
+	for round := 0; round < 5; round++ {
+		for i, p := range peers {
+			for j := 0; j < 100; j++ {
+				p.Add([]byte(fmt.Sprintf("peer%d_round%d_%d", i, round, j)))
+			}
+		}
+		// Ring exchange: changes reach every peer through intermediaries
+		for i := range peers {
+			ship(i, (i+1)%numPeers)
+		}
+	}
+	for i := range peers {
+		ship(i, (i+1)%numPeers)
+	}
+
+	for i := range peers[0].filter {
+		if peers[0].filter[i] != peers[1].filter[i] || peers[1].filter[i] != peers[2].filter[i] {
+			t.Fatalf("Replicas differ at word %d", i)
+		}
+	}
+	for round := 0; round < 5; round++ {
+		for i := range peers {
+			for j := 0; j < 100; j++ {
+				if !peers[0].Check([]byte(fmt.Sprintf("peer%d_round%d_%d", i, round, j))) {
+					t.Fatalf("Element from peer %d missing after sync", i)
+				}
+			}
+		}
+	}
+}
+
+func TestApplyDeltaConcurrentWithAdd(t *testing.T) {
+	source, err := NewDefaultStableBloomFilter(10000, 0.01, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer source.StopDecay()
+	target, err := NewDefaultStableBloomFilter(10000, 0.01, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer target.StopDecay()
+	source.EnableDeltaTracking()
+	target.EnableDeltaTracking()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 5000; i++ {
+			target.Add([]byte(fmt.Sprintf("local_%d", i)))
+		}
+	}()
+	since := uint64(0)
+	for round := 0; round < 50; round++ {
+		for i := 0; i < 20; i++ {
+			source.Add([]byte(fmt.Sprintf("remote_%d_%d", round, i)))
+		}
+		d, err := source.Delta(since)
+		if err != nil {
+			t.Fatalf("Delta failed: %v", err)
+		}
+		if err := target.ApplyDelta(d); err != nil {
+			t.Fatalf("ApplyDelta failed: %v", err)
+		}
+		since = d.Next
+	}
+	wg.Wait()
+
+	// Concurrent Adds must not overwrite replicated bits
+	for round := 0; round < 50; round++ {
+		for i := 0; i < 20; i++ {
+			if !target.Check([]byte(fmt.Sprintf("remote_%d_%d", round, i))) {
+				t.Fatalf("Replicated element remote_%d_%d was lost", round, i)
+			}
+		}
+	}
+	for i := 0; i < 5000; i++ {
+		if !target.Check([]byte(fmt.Sprintf("local_%d", i))) {
+			t.Fatalf("Local element local_%d was lost", i)
+		}
+	}
+}
+
+func TestApplyDeltaRejectsIncompatibleHashing(t *testing.T) {
+	newTracked := func(alg HashAlgorithm, layout Layout) *StableBloomFilter {
+		t.Helper()
+		f, err := newStableBloomFilterWithHash(1024, 4, alg, nil, layout, 0.0, time.Hour)
+		if err != nil {
+			t.Fatalf("Failed to create StableBloomFilter: %v", err)
+		}
+		t.Cleanup(f.StopDecay)
+		f.EnableDeltaTracking()
+		f.Add([]byte("element"))
+		return f
+	}
+
+	tests := []struct {
+		name      string
+		from, to  *StableBloomFilter
+		wantParam string
+	}{
+		{"algorithm", newTracked(HashXXH3Seeded, LayoutStandard), newTracked(HashFNV1a, LayoutStandard), "hash"},
+		{"random keys", newTracked(HashSipHash, LayoutStandard), newTracked(HashSipHash, LayoutStandard), "hash"},
+		{"layout", newTracked(HashXXH3Seeded, LayoutPartitioned), newTracked(HashXXH3Seeded, LayoutStandard), "layout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := tt.from.Delta(0)
+			if err != nil {
+				t.Fatalf("Delta failed: %v", err)
+			}
+			encoded, err := d.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary failed: %v", err)
+			}
+			var decoded Delta
+			if err := decoded.UnmarshalBinary(encoded); err != nil {
+				t.Fatalf("UnmarshalBinary failed: %v", err)
+			}
+
+			before := append([]uint64(nil), tt.to.filter...)
+			var incompatible *IncompatibleError
+			if err := tt.to.ApplyDelta(&decoded); !errors.As(err, &incompatible) || incompatible.Param != tt.wantParam {
+				t.Fatalf("Expected an IncompatibleError on %s, got %v", tt.wantParam, err)
+			}
+			for i := range before {
+				if tt.to.filter[i] != before[i] {
+					t.Fatalf("Rejected delta modified word %d", i)
+				}
+			}
+			if err := tt.from.ApplyDelta(&decoded); err != nil {
+				t.Errorf("Expected the source filter to accept its own delta, got %v", err)
+			}
+		})
+	}
+}
+
+func TestApplyDeltaRejectsInvalid(t *testing.T) {
+	sbf, err := NewStableBloomFilter(1024, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+
+	sbf.EnableDeltaTracking()
+	valid, err := sbf.Delta(0)
+	if err != nil {
+		t.Fatalf("Delta failed: %v", err)
+	}
+
+	if err := sbf.ApplyDelta(&Delta{M: 2048, K: sbf.k}); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("Expected ErrIncompatible, got %v", err)
+	}
+	outOfRange := *valid
+	outOfRange.Indexes, outOfRange.Words = []uint32{16}, []uint64{1}
+	if err := sbf.ApplyDelta(&outOfRange); err == nil || errors.Is(err, ErrIncompatible) {
+		t.Errorf("Expected an error for an out of range index, got %v", err)
+	}
+
+	var d Delta
+	if err := d.UnmarshalBinary([]byte{deltaFormatVersion, 1, 2}); err == nil {
+		t.Error("Expected an error for truncated data")
+	}
+}
@@ -0,0 +1,423 @@
+package sbf
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// Serialized filter layout, specified in FORMAT.md. All integers are little-endian.
+//
+//	offset  size  field
+//	0       3     magic "SBF"
+//	3       1     format version (1)
+//	4       1     bit array encoding (encodingRaw, encodingRLE or encodingFlate)
+//	5       1     hash algorithm (HashAlgorithm)
+//	6       1     layout (Layout)
+//	7       1     reserved, must be zero
+//	8       4     m, size of the filter in bits
+//	12      4     k, number of hash functions
+//	16      8     decay rate (IEEE 754 binary64)
+//	24      8     decay interval in nanoseconds
+//	32      4     number of hash seeds s
+//	36      8*s   hash seeds
+//	36+8s   4     length of the encoded bit array in bytes
+//	40+8s   ...   encoded bit array
+const (
+	formatVersion = 1
+	headerSize    = 36
+	maxSeeds      = 1024 // Upper bound on the seed count accepted when decoding
+	maxHashFuncs  = 1024 // Upper bound on k for filters built from a named hash algorithm
+)
+
+var magic = [3]byte{'S', 'B', 'F'}
+
+// Bit array encodings.
+const (
+	encodingRaw   = 0 // m/64 words, each 8 bytes
+	encodingRLE   = 1 // Runs of zero words followed by literal words
+	encodingFlate = 2 // The raw encoding compressed with DEFLATE
+)
+
+// Density thresholds used to pick an encoding.
+const (
+	rleMinZeroWords   = 0.5 // Fraction of empty words above which RLE is used
+	flateMaxFillRatio = 0.2 // Fill ratio below which DEFLATE is used
+)
+
+// ErrCustomHash is returned when serializing a filter built with custom hash functions,
+// which cannot be recreated when the filter is restored. Build the filter from a registered
+// HashAlgorithm to make it serializable.
+var ErrCustomHash = errors.New("filter uses custom hash functions and cannot be serialized; use a registered HashAlgorithm")
+
+// ErrInitialized is returned when decoding into a StableBloomFilter that is already in use.
+var ErrInitialized = errors.New("cannot decode into an initialized filter")
+
+// MarshalBinary encodes the Stable Bloom Filter, including its parameters and bit array.
+//
+// The bit array is stored raw, run-length encoded or DEFLATE-compressed depending on its density,
+// so freshly decayed, mostly empty filters are much smaller than m/8 bytes. The bit array is read
+// word by word while the filter may be in use, so concurrent Adds may be partially captured.
+//
+// Returns:
+//   - The encoded filter.
+//   - ErrCustomHash if the filter was built with custom hash functions.
+func (sbf *StableBloomFilter) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := sbf.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteTo writes the encoded Stable Bloom Filter to w, in the format produced by MarshalBinary.
+func (sbf *StableBloomFilter) WriteTo(w io.Writer) (int64, error) {
+	if sbf.hashAlg == HashCustom {
+		return 0, ErrCustomHash
+	}
+	words := sbf.snapshot()
+	return sbf.writeWords(w, words, chooseEncoding(words))
+}
+
+// writeWords writes the filter's header followed by the given bit array in the given encoding.
+func (sbf *StableBloomFilter) writeWords(w io.Writer, words []uint64, encoding byte) (int64, error) {
+	payload, err := encodeWords(words, encoding)
+	if err != nil {
+		return 0, err
+	}
+
+	header := make([]byte, headerSize, headerSize+8*len(sbf.seeds)+4)
+	copy(header, magic[:])
+	header[3] = formatVersion
+	header[4] = encoding
+	header[5] = byte(sbf.hashAlg)
+	header[6] = byte(sbf.layout)
+	binary.LittleEndian.PutUint32(header[8:], sbf.m)
+	binary.LittleEndian.PutUint32(header[12:], sbf.k)
+	binary.LittleEndian.PutUint64(header[16:], math.Float64bits(sbf.decayRate))
+	binary.LittleEndian.PutUint64(header[24:], uint64(sbf.decayPeriod))
+	binary.LittleEndian.PutUint32(header[32:], uint32(len(sbf.seeds)))
+	for _, seed := range sbf.seeds {
+		header = binary.LittleEndian.AppendUint64(header, seed)
+	}
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(payload)))
+
+	n, err := w.Write(header)
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := w.Write(payload)
+	return int64(n + m), err
+}
+
+// UnmarshalStableBloomFilter restores a Stable Bloom Filter encoded by MarshalBinary.
+//
+// The restored filter starts its own decay process with the encoded decay settings, so StopDecay
+// must be called on it when it is no longer needed.
+//
+// Returns:
+//   - A pointer to the restored StableBloomFilter.
+//   - An error if the data is not a valid encoded filter.
+func UnmarshalStableBloomFilter(data []byte) (*StableBloomFilter, error) {
+	return LoadStableBloomFilter(bytes.NewReader(data))
+}
+
+// LoadStableBloomFilter reads a Stable Bloom Filter written by WriteTo from r.
+//
+// See UnmarshalStableBloomFilter.
+func LoadStableBloomFilter(r io.Reader) (*StableBloomFilter, error) {
+	h, words, err := readFilter(r)
+	if err != nil {
+		return nil, err
+	}
+	sbf := &StableBloomFilter{}
+	if err := sbf.restore(h, words); err != nil {
+		return nil, err
+	}
+	return sbf, nil
+}
+
+// GobEncode implements gob.GobEncoder using the format produced by MarshalBinary.
+func (sbf *StableBloomFilter) GobEncode() ([]byte, error) {
+	return sbf.MarshalBinary()
+}
+
+// GobDecode implements gob.GobDecoder.
+//
+// The receiver must be a zero StableBloomFilter, such as a new variable. The decoded filter starts
+// its own decay process, so StopDecay must be called on it when it is no longer needed.
+func (sbf *StableBloomFilter) GobDecode(data []byte) error {
+	if sbf.filter != nil {
+		return ErrInitialized
+	}
+	h, words, err := readFilter(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	return sbf.restore(h, words)
+}
+
+// restore initializes a zero StableBloomFilter from decoded parameters and bit array.
+func (sbf *StableBloomFilter) restore(h *filterHeader, words []uint64) error {
+	hashFuncs, err := buildHashFuncs(h.hashAlg, h.k, h.seeds)
+	if err != nil {
+		return err
+	}
+	sbf.init(h.m, hashFuncs, h.hashAlg, h.seeds, h.layout, h.decayRate, h.decayInterval)
+	copy(sbf.filter, words)
+	return nil
+}
+
+// filterHeader holds the decoded parameters of a serialized filter.
+type filterHeader struct {
+	encoding      byte
+	m             uint32
+	k             uint32
+	decayRate     float64
+	decayInterval time.Duration
+	hashAlg       HashAlgorithm
+	layout        Layout
+	seeds         []uint64
+}
+
+// validate checks that the decoded parameters describe a usable filter.
+func (h *filterHeader) validate() error {
+	if h.m == 0 || h.m%64 != 0 {
+		return fmt.Errorf("invalid filter size %d", h.m)
+	}
+	if h.decayInterval <= 0 {
+		return fmt.Errorf("invalid decay interval %v", h.decayInterval)
+	}
+	if h.k == 0 || h.k > maxHashFuncs {
+		return fmt.Errorf("invalid hash function count %d", h.k)
+	}
+	switch h.layout {
+	case LayoutStandard:
+	case LayoutPartitioned:
+		if uint64(h.m)%(64*uint64(h.k)) != 0 {
+			return fmt.Errorf("filter size %d is not divisible into %d whole-word partitions", h.m, h.k)
+		}
+	default:
+		return fmt.Errorf("unsupported layout %d", h.layout)
+	}
+	f, ok := lookupHashFamily(h.hashAlg)
+	if !ok {
+		return fmt.Errorf("unsupported hash algorithm %d", h.hashAlg)
+	}
+	if want := f.NumSeeds(h.k); len(h.seeds) != want {
+		return fmt.Errorf("expected %d hash seeds, got %d", want, len(h.seeds))
+	}
+	return nil
+}
+
+// readFilter reads and validates a serialized filter, returning its parameters and bit array.
+func readFilter(r io.Reader) (*filterHeader, []uint64, error) {
+	var fixed [headerSize]byte
+	if _, err := io.ReadFull(r, fixed[:]); err != nil {
+		return nil, nil, fmt.Errorf("reading filter header: %w", err)
+	}
+	if !bytes.Equal(fixed[:3], magic[:]) {
+		return nil, nil, errors.New("not a serialized Stable Bloom Filter")
+	}
+	if fixed[3] != formatVersion {
+		return nil, nil, fmt.Errorf("unsupported format version %d", fixed[3])
+	}
+	if fixed[7] != 0 {
+		return nil, nil, errors.New("reserved header byte is not zero")
+	}
+
+	h := &filterHeader{
+		encoding:      fixed[4],
+		m:             binary.LittleEndian.Uint32(fixed[8:]),
+		k:             binary.LittleEndian.Uint32(fixed[12:]),
+		decayRate:     math.Float64frombits(binary.LittleEndian.Uint64(fixed[16:])),
+		decayInterval: time.Duration(binary.LittleEndian.Uint64(fixed[24:])),
+		hashAlg:       HashAlgorithm(fixed[5]),
+		layout:        Layout(fixed[6]),
+	}
+	numSeeds := binary.LittleEndian.Uint32(fixed[32:])
+	if numSeeds > maxSeeds {
+		return nil, nil, fmt.Errorf("invalid hash seed count %d", numSeeds)
+	}
+	rest := make([]byte, 8*int(numSeeds)+4)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return nil, nil, fmt.Errorf("reading hash seeds: %w", err)
+	}
+	h.seeds = make([]uint64, numSeeds)
+	for i := range h.seeds {
+		h.seeds[i] = binary.LittleEndian.Uint64(rest[8*i:])
+	}
+	if err := h.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	payloadLen := binary.LittleEndian.Uint32(rest[8*numSeeds:])
+	if maxLen := uint64(h.m/64)*9 + 64; uint64(payloadLen) > maxLen {
+		return nil, nil, fmt.Errorf("bit array of %d bytes is too large", payloadLen)
+	}
+	payload := make([]byte, payloadLen)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, nil, fmt.Errorf("reading bit array: %w", err)
+	}
+
+	words, err := decodeWords(payload, h.encoding, h.m/64)
+	if err != nil {
+		return nil, nil, err
+	}
+	return h, words, nil
+}
+
+// snapshot copies the bit array.
+func (sbf *StableBloomFilter) snapshot() []uint64 {
+	words := make([]uint64, len(sbf.filter))
+	for i := range sbf.filter {
+		words[i] = atomic.LoadUint64(&sbf.filter[i])
+	}
+	return words
+}
+
+// chooseEncoding picks the most compact encoding for a bit array based on its density.
+func chooseEncoding(words []uint64) byte {
+	var zeroWords, bitsSet int
+	for _, w := range words {
+		if w == 0 {
+			zeroWords++
+		}
+		bitsSet += bits.OnesCount64(w)
+	}
+
+	switch {
+	case float64(zeroWords) >= rleMinZeroWords*float64(len(words)):
+		return encodingRLE
+	case float64(bitsSet) < flateMaxFillRatio*64*float64(len(words)):
+		return encodingFlate
+	default:
+		return encodingRaw
+	}
+}
+
+// encodeWords encodes a bit array with the given encoding.
+func encodeWords(words []uint64, encoding byte) ([]byte, error) {
+	switch encoding {
+	case encodingRaw:
+		return appendRaw(make([]byte, 0, 8*len(words)), words), nil
+	case encodingRLE:
+		return appendRLE(nil, words), nil
+	case encodingFlate:
+		var buf bytes.Buffer
+		fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fw.Write(appendRaw(make([]byte, 0, 8*len(words)), words)); err != nil {
+			return nil, err
+		}
+		if err := fw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown bit array encoding %d", encoding)
+	}
+}
+
+// decodeWords decodes a bit array of numWords words.
+func decodeWords(payload []byte, encoding byte, numWords uint32) ([]uint64, error) {
+	switch encoding {
+	case encodingRaw:
+		return decodeRaw(payload, numWords)
+	case encodingRLE:
+		return decodeRLE(payload, numWords)
+	case encodingFlate:
+		raw, err := io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(payload)), int64(numWords)*8+1))
+		if err != nil {
+			return nil, fmt.Errorf("decompressing bit array: %w", err)
+		}
+		return decodeRaw(raw, numWords)
+	default:
+		return nil, fmt.Errorf("unknown bit array encoding %d", encoding)
+	}
+}
+
+// appendRaw appends each word as 8 little-endian bytes.
+func appendRaw(buf []byte, words []uint64) []byte {
+	for _, w := range words {
+		buf = binary.LittleEndian.AppendUint64(buf, w)
+	}
+	return buf
+}
+
+// decodeRaw decodes words written by appendRaw.
+func decodeRaw(raw []byte, numWords uint32) ([]uint64, error) {
+	if len(raw) != int(numWords)*8 {
+		return nil, fmt.Errorf("bit array has %d bytes, expected %d", len(raw), int(numWords)*8)
+	}
+	words := make([]uint64, numWords)
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint64(raw[8*i:])
+	}
+	return words, nil
+}
+
+// appendRLE appends the words as a sequence of (zero run length, literal count, literal words)
+// groups, with the lengths stored as uvarints and the literals as 8 little-endian bytes.
+func appendRLE(buf []byte, words []uint64) []byte {
+	for i := 0; i < len(words); {
+		start := i
+		for i < len(words) && words[i] == 0 {
+			i++
+		}
+		zeros := i - start
+
+		start = i
+		for i < len(words) && words[i] != 0 {
+			i++
+		}
+		buf = binary.AppendUvarint(buf, uint64(zeros))
+		buf = binary.AppendUvarint(buf, uint64(i-start))
+		buf = appendRaw(buf, words[start:i])
+	}
+	return buf
+}
+
+// decodeRLE decodes words written by appendRLE.
+func decodeRLE(payload []byte, numWords uint32) ([]uint64, error) {
+	words := make([]uint64, numWords)
+	pos := uint64(0)
+	for len(payload) > 0 {
+		zeros, n := binary.Uvarint(payload)
+		if n <= 0 {
+			return nil, errors.New("invalid run length")
+		}
+		payload = payload[n:]
+		literals, n := binary.Uvarint(payload)
+		if n <= 0 {
+			return nil, errors.New("invalid literal count")
+		}
+		payload = payload[n:]
+
+		if zeros > uint64(numWords)-pos || literals > uint64(numWords)-pos-zeros {
+			return nil, errors.New("run-length encoded bit array is too long")
+		}
+		pos += zeros
+		if uint64(len(payload)) < literals*8 {
+			return nil, errors.New("truncated run-length encoded bit array")
+		}
+		for i := uint64(0); i < literals; i++ {
+			words[pos] = binary.LittleEndian.Uint64(payload[8*i:])
+			pos++
+		}
+		payload = payload[literals*8:]
+	}
+	if pos != uint64(numWords) {
+		return nil, fmt.Errorf("run-length encoded bit array has %d words, expected %d", pos, numWords)
+	}
+	return words, nil
+}
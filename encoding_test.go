@@ -0,0 +1,179 @@
+package sbf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMarshalBinaryRoundTrip(t *testing.T) {
+	tests := []struct {
+		name         string
+		elements     int
+		wantEncoding byte
+	}{
+		{name: "empty", elements: 0, wantEncoding: encodingRLE},
+		{name: "sparse words", elements: 50, wantEncoding: encodingRLE},
+		{name: "sparse bits", elements: 1500, wantEncoding: encodingFlate},
+		{name: "dense", elements: 20000, wantEncoding: encodingRaw},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sbf, err := NewStableBloomFilter(64*1024, nil, 0.02, 3*time.Hour)
+			if err != nil {
+				t.Fatalf("Failed to create StableBloomFilter: %v", err)
+			}
+			defer sbf.StopDecay()
+
+			for i := 0; i < tt.elements; i++ {
+				sbf.Add([]byte(fmt.Sprintf("element%d", i)))
+			}
+
+			data, err := sbf.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary failed: %v", err)
+			}
+			if data[4] != tt.wantEncoding {
+				t.Errorf("Expected encoding %d, got %d", tt.wantEncoding, data[4])
+			}
+			if tt.wantEncoding != encodingRaw && len(data) >= int(sbf.m/8) {
+				t.Errorf("Expected compressed size below %d bytes, got %d", sbf.m/8, len(data))
+			}
+
+			restored, err := UnmarshalStableBloomFilter(data)
+			if err != nil {
+				t.Fatalf("UnmarshalStableBloomFilter failed: %v", err)
+			}
+			defer restored.StopDecay()
+
+			if restored.m != sbf.m || restored.k != sbf.k || restored.decayRate != sbf.decayRate || restored.decayPeriod != sbf.decayPeriod {
+				t.Errorf("Parameters differ after round trip")
+			}
+			for i := range sbf.filter {
+				if restored.filter[i] != sbf.filter[i] {
+					t.Fatalf("Word %d differs after round trip", i)
+				}
+			}
+			for i := 0; i < tt.elements; i++ {
+				if !restored.Check([]byte(fmt.Sprintf("element%d", i))) {
+					t.Fatalf("Element %d missing after round trip", i)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteToLoad(t *testing.T) {
+	sbf, err := NewDefaultStableBloomFilter(1000, 0.01, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+	sbf.Add([]byte("element"))
+
+	var buf bytes.Buffer
+	n, err := sbf.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo reported %d bytes, wrote %d", n, buf.Len())
+	}
+
+	restored, err := LoadStableBloomFilter(&buf)
+	if err != nil {
+		t.Fatalf("LoadStableBloomFilter failed: %v", err)
+	}
+	defer restored.StopDecay()
+
+	if !restored.Check([]byte("element")) {
+		t.Error("Element missing after round trip")
+	}
+	if err := restored.Merge(sbf); err != nil {
+		t.Errorf("Restored filter should be compatible with the original: %v", err)
+	}
+}
+
+func TestMarshalBinaryCustomHash(t *testing.T) {
+	sbf, err := NewStableBloomFilter(1024, []Hash64{makeHashFunc(42)}, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+
+	if _, err := sbf.MarshalBinary(); !errors.Is(err, ErrCustomHash) {
+		t.Errorf("Expected ErrCustomHash, got %v", err)
+	}
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+	sbf, err := NewStableBloomFilter(1024, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+	sbf.Add([]byte("element"))
+
+	valid, err := sbf.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+
+	corrupt := func(f func(b []byte) []byte) []byte {
+		return f(append([]byte(nil), valid...))
+	}
+
+	// SipHash needs two seeds for any k, so only the k bound rejects this header
+	keyed, err := NewStableBloomFilterWithHash(1024, 3, HashSipHash, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer keyed.StopDecay()
+	hugeK, err := keyed.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	binary.LittleEndian.PutUint32(hugeK[12:], 1<<28)
+
+	tests := map[string][]byte{
+		"empty":       {},
+		"bad magic":   corrupt(func(b []byte) []byte { b[0] = 'X'; return b }),
+		"bad version": corrupt(func(b []byte) []byte { b[3] = 99; return b }),
+		"bad hash":    corrupt(func(b []byte) []byte { b[5] = 99; return b }),
+		"bad layout":  corrupt(func(b []byte) []byte { b[6] = 99; return b }),
+		"reserved":    corrupt(func(b []byte) []byte { b[7] = 1; return b }),
+		"bad size":    corrupt(func(b []byte) []byte { b[8] = 1; return b }),
+		"bad seeds":   corrupt(func(b []byte) []byte { b[32] = 3; return b }),
+		"truncated":   valid[:len(valid)-1],
+		"huge k":      hugeK,
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if f, err := UnmarshalStableBloomFilter(data); err == nil {
+				f.StopDecay()
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestRLEEncoding(t *testing.T) {
+	words := []uint64{0, 0, 5, 6, 0, 7, 0, 0}
+	decoded, err := decodeRLE(appendRLE(nil, words), uint32(len(words)))
+	if err != nil {
+		t.Fatalf("decodeRLE failed: %v", err)
+	}
+	for i := range words {
+		if decoded[i] != words[i] {
+			t.Fatalf("Word %d: expected %d, got %d", i, words[i], decoded[i])
+		}
+	}
+
+	if _, err := decodeRLE(appendRLE(nil, words), 4); err == nil {
+		t.Error("Expected an error decoding more words than expected")
+	}
+}
@@ -0,0 +1,234 @@
+package sbf
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/zeebo/xxh3"
+)
+
+// Filter is an approximate membership filter that forgets old elements.
+//
+// It is the method set of StableBloomFilter, implemented by every filter in this package, so
+// implementations can be swapped per workload and replaced by fakes in tests. Implementations
+// must be safe for concurrent use by multiple goroutines.
+type Filter interface {
+	// Add inserts an element into the filter.
+	Add(data []byte)
+	// Check tests if an element might be in the filter.
+	Check(data []byte) bool
+	// EstimateFalsePositiveRate estimates the current false positive rate of the filter.
+	EstimateFalsePositiveRate() float64
+	// StopDecay stops the background process that forgets old elements.
+	StopDecay()
+}
+
+var (
+	_ Filter = (*StableBloomFilter)(nil)
+	_ Filter = (*CountingStableBloomFilter)(nil)
+	_ Filter = (*ScalableFilter)(nil)
+	_ Filter = (*WindowedFilter)(nil)
+	_ Filter = (*TimingBloomFilter)(nil)
+	_ Filter = (*CuckooFilter)(nil)
+	_ Filter = (*AgePartitionedFilter)(nil)
+	_ Filter = (*MetricsFilter)(nil)
+	_ Filter = (*LoggingFilter)(nil)
+	_ Filter = (*ShardedFilter)(nil)
+)
+
+// Metric names reported by MetricsFilter in addition to the shared ones.
+const (
+	MetricCheckHits = "sbf_check_hits_total"
+)
+
+// MetricsFilter is a Filter that counts the Add and Check calls made through it.
+//
+// It reports counts for any Filter, while Stats is specific to StableBloomFilter.
+type MetricsFilter struct {
+	Filter
+	adds   shardedCounter
+	checks shardedCounter
+	hits   shardedCounter
+}
+
+// FilterCounts is a snapshot of the calls counted by a MetricsFilter.
+type FilterCounts struct {
+	Adds   uint64 // Cumulative number of Add calls
+	Checks uint64 // Cumulative number of Check calls
+	Hits   uint64 // Cumulative number of Check calls that returned true
+}
+
+// NewMetricsFilter wraps a Filter to count the calls made through it.
+func NewMetricsFilter(f Filter) *MetricsFilter {
+	return &MetricsFilter{
+		Filter: f,
+		adds:   newShardedCounter(),
+		checks: newShardedCounter(),
+		hits:   newShardedCounter(),
+	}
+}
+
+// Add inserts an element into the wrapped filter.
+func (m *MetricsFilter) Add(data []byte) {
+	m.adds.inc()
+	m.Filter.Add(data)
+}
+
+// Check tests if an element might be in the wrapped filter.
+func (m *MetricsFilter) Check(data []byte) bool {
+	m.checks.inc()
+	found := m.Filter.Check(data)
+	if found {
+		m.hits.inc()
+	}
+	return found
+}
+
+// Counts returns the number of calls made through the filter.
+func (m *MetricsFilter) Counts() FilterCounts {
+	return FilterCounts{
+		Adds:   m.adds.load(),
+		Checks: m.checks.load(),
+		Hits:   m.hits.load(),
+	}
+}
+
+// Metrics returns the counts and the estimated false positive rate as named metrics.
+func (m *MetricsFilter) Metrics() []Metric {
+	c := m.Counts()
+	return []Metric{
+		{MetricEstimatedFPR, "Estimated false positive rate of the filter.", GaugeMetric, m.EstimateFalsePositiveRate()},
+		{MetricAdds, "Total number of Add calls.", CounterMetric, float64(c.Adds)},
+		{MetricChecks, "Total number of Check calls.", CounterMetric, float64(c.Checks)},
+		{MetricCheckHits, "Total number of Check calls that reported the element present.", CounterMetric, float64(c.Hits)},
+	}
+}
+
+// Unwrap returns the wrapped filter.
+func (m *MetricsFilter) Unwrap() Filter {
+	return m.Filter
+}
+
+// LoggingFilter is a Filter that logs the calls made through it.
+//
+// Add and Check are logged at debug level with the element's length, never its contents, and
+// StopDecay at info level. Records are skipped cheaply when the level is disabled.
+type LoggingFilter struct {
+	Filter
+	logger *slog.Logger
+}
+
+// NewLoggingFilter wraps a Filter to log the calls made through it.
+//
+// Parameters:
+//   - f: Filter to wrap.
+//   - logger: Logger to write to. If nil, slog.Default() is used.
+//
+// Returns:
+//   - A pointer to the LoggingFilter.
+func NewLoggingFilter(f Filter, logger *slog.Logger) *LoggingFilter {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &LoggingFilter{Filter: f, logger: logger}
+}
+
+// Add inserts an element into the wrapped filter.
+func (l *LoggingFilter) Add(data []byte) {
+	l.Filter.Add(data)
+	if l.logger.Enabled(context.Background(), slog.LevelDebug) {
+		l.logger.LogAttrs(context.Background(), slog.LevelDebug, "sbf add", slog.Int("bytes", len(data)))
+	}
+}
+
+// Check tests if an element might be in the wrapped filter.
+func (l *LoggingFilter) Check(data []byte) bool {
+	found := l.Filter.Check(data)
+	if l.logger.Enabled(context.Background(), slog.LevelDebug) {
+		l.logger.LogAttrs(context.Background(), slog.LevelDebug, "sbf check",
+			slog.Int("bytes", len(data)), slog.Bool("found", found))
+	}
+	return found
+}
+
+// StopDecay stops the wrapped filter's decay process.
+func (l *LoggingFilter) StopDecay() {
+	l.Filter.StopDecay()
+	l.logger.LogAttrs(context.Background(), slog.LevelInfo, "sbf stopped",
+		slog.Float64("estimated_fpr", l.Filter.EstimateFalsePositiveRate()))
+}
+
+// Unwrap returns the wrapped filter.
+func (l *LoggingFilter) Unwrap() Filter {
+	return l.Filter
+}
+
+// shardSeed seeds the hash that picks a shard, keeping it independent of the shards' own hashes.
+const shardSeed = 0x5348415244 // "SHARD"
+
+// ShardedFilter is a Filter that spreads elements over several filters by hash.
+//
+// Each element always maps to the same shard, so shards can be sized, decayed and stopped
+// independently, and contention is split between them.
+type ShardedFilter struct {
+	shards []Filter
+}
+
+// NewShardedFilter creates a Filter that spreads elements over the given shards.
+//
+// Parameters:
+//   - shards: Filters to spread elements over. The order determines which shard an element maps to.
+//
+// Returns:
+//   - A pointer to the ShardedFilter.
+//   - An error if no shards are given or a shard is nil.
+func NewShardedFilter(shards ...Filter) (*ShardedFilter, error) {
+	if len(shards) == 0 {
+		return nil, errors.New("at least one shard is required")
+	}
+	for _, shard := range shards {
+		if shard == nil {
+			return nil, errors.New("shards must not be nil")
+		}
+	}
+	return &ShardedFilter{shards: append([]Filter(nil), shards...)}, nil
+}
+
+// Add inserts an element into its shard.
+func (s *ShardedFilter) Add(data []byte) {
+	s.Shard(data).Add(data)
+}
+
+// Check tests if an element might be in its shard.
+func (s *ShardedFilter) Check(data []byte) bool {
+	return s.Shard(data).Check(data)
+}
+
+// EstimateFalsePositiveRate estimates the current false positive rate of the ShardedFilter.
+//
+// Elements map to shards uniformly, so the estimate is the mean of the shards' estimates.
+func (s *ShardedFilter) EstimateFalsePositiveRate() float64 {
+	var sum float64
+	for _, shard := range s.shards {
+		sum += shard.EstimateFalsePositiveRate()
+	}
+	return sum / float64(len(s.shards))
+}
+
+// StopDecay stops the decay process of every shard.
+func (s *ShardedFilter) StopDecay() {
+	for _, shard := range s.shards {
+		shard.StopDecay()
+	}
+}
+
+// Shard returns the shard an element maps to.
+func (s *ShardedFilter) Shard(data []byte) Filter {
+	return s.shards[xxh3.HashSeed(data, shardSeed)%uint64(len(s.shards))]
+}
+
+// Shards returns the shards of the filter.
+func (s *ShardedFilter) Shards() []Filter {
+	return append([]Filter(nil), s.shards...)
+}
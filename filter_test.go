@@ -0,0 +1,192 @@
+package sbf
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeFilter is an exact set, standing in for a Filter in tests.
+type fakeFilter struct {
+	mu      sync.Mutex
+	items   map[string]bool
+	stopped bool
+}
+
+func newFakeFilter() *fakeFilter {
+	return &fakeFilter{items: make(map[string]bool)}
+}
+
+func (f *fakeFilter) Add(data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.items[string(data)] = true
+}
+
+func (f *fakeFilter) Check(data []byte) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.items[string(data)]
+}
+
+func (f *fakeFilter) EstimateFalsePositiveRate() float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return float64(len(f.items)) / 1000
+}
+
+func (f *fakeFilter) StopDecay() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopped = true
+}
+
+func TestFilterImplementations(t *testing.T) {
+	newFilters := map[string]func() (Filter, error){
+		"StableBloomFilter": func() (Filter, error) {
+			return NewDefaultStableBloomFilter(1000, 0.01, 0, time.Hour)
+		},
+		"CountingStableBloomFilter": func() (Filter, error) {
+			return NewCountingStableBloomFilter(1<<14, 4, nil, 0.01, time.Hour)
+		},
+		"ScalableFilter": func() (Filter, error) {
+			return NewScalableFilter(100, 0.01, 0, time.Hour)
+		},
+		"WindowedFilter": func() (Filter, error) {
+			return NewWindowedFilter(1<<14, nil, 4, time.Hour)
+		},
+		"TimingBloomFilter": func() (Filter, error) {
+			return NewTimingBloomFilter(1<<14, nil, time.Hour, 0)
+		},
+		"CuckooFilter": func() (Filter, error) {
+			return NewCuckooFilter(1000, 3, time.Hour)
+		},
+		"AgePartitionedFilter": func() (Filter, error) {
+			return NewDefaultAgePartitionedFilter(1000, 0.01)
+		},
+	}
+
+	for name, newFilter := range newFilters {
+		t.Run(name, func(t *testing.T) {
+			f, err := newFilter()
+			if err != nil {
+				t.Fatalf("Failed to create %s: %v", name, err)
+			}
+			defer f.StopDecay()
+
+			for i := 0; i < 100; i++ {
+				f.Add([]byte(fmt.Sprintf("element_%d", i)))
+			}
+			for i := 0; i < 100; i++ {
+				if !f.Check([]byte(fmt.Sprintf("element_%d", i))) {
+					t.Fatalf("element_%d should be present", i)
+				}
+			}
+			if fpr := f.EstimateFalsePositiveRate(); fpr < 0 || fpr >= 1 {
+				t.Errorf("Unexpected false positive rate estimate %f", fpr)
+			}
+		})
+	}
+}
+
+func TestMetricsFilter(t *testing.T) {
+	m := NewMetricsFilter(newFakeFilter())
+	m.Add([]byte("a"))
+	m.Add([]byte("b"))
+	m.Check([]byte("a"))
+	m.Check([]byte("c"))
+	m.Check([]byte("b"))
+
+	want := FilterCounts{Adds: 2, Checks: 3, Hits: 2}
+	if got := m.Counts(); got != want {
+		t.Errorf("Expected counts %+v, got %+v", want, got)
+	}
+
+	values := make(map[string]float64)
+	for _, metric := range m.Metrics() {
+		values[metric.Name] = metric.Value
+	}
+	if values[MetricAdds] != 2 || values[MetricChecks] != 3 || values[MetricCheckHits] != 2 {
+		t.Errorf("Unexpected metric values %v", values)
+	}
+	if values[MetricEstimatedFPR] != 0.002 {
+		t.Errorf("Expected the wrapped filter's estimate 0.002, got %f", values[MetricEstimatedFPR])
+	}
+
+	m.StopDecay()
+	if !m.Unwrap().(*fakeFilter).stopped {
+		t.Error("StopDecay was not passed to the wrapped filter")
+	}
+}
+
+func TestLoggingFilter(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	l := NewLoggingFilter(newFakeFilter(), logger)
+
+	l.Add([]byte("secret-token"))
+	if !l.Check([]byte("secret-token")) {
+		t.Error("Element missing after Add")
+	}
+	l.StopDecay()
+
+	out := buf.String()
+	for _, want := range []string{"msg=\"sbf add\" bytes=12", "msg=\"sbf check\" bytes=12 found=true", "msg=\"sbf stopped\""} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected log to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "secret-token") {
+		t.Error("Element contents should not be logged")
+	}
+}
+
+func TestShardedFilter(t *testing.T) {
+	shards := []Filter{newFakeFilter(), newFakeFilter(), newFakeFilter(), newFakeFilter()}
+	s, err := NewShardedFilter(shards...)
+	if err != nil {
+		t.Fatalf("Failed to create ShardedFilter: %v", err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		s.Add([]byte(fmt.Sprintf("element_%d", i)))
+	}
+	var total int
+	for i, shard := range s.Shards() {
+		n := len(shard.(*fakeFilter).items)
+		if n < 150 || n > 350 {
+			t.Errorf("Shard %d holds %d of 1000 elements; expected an even spread", i, n)
+		}
+		total += n
+	}
+	if total != 1000 {
+		t.Errorf("Expected each element in exactly one shard, got %d entries", total)
+	}
+	for i := 0; i < 1000; i++ {
+		data := []byte(fmt.Sprintf("element_%d", i))
+		if !s.Check(data) || !s.Shard(data).Check(data) {
+			t.Fatalf("element_%d not found in its shard", i)
+		}
+	}
+	if fpr := s.EstimateFalsePositiveRate(); fpr != 0.25 {
+		t.Errorf("Expected the mean shard estimate 0.25, got %f", fpr)
+	}
+
+	s.StopDecay()
+	for i, shard := range shards {
+		if !shard.(*fakeFilter).stopped {
+			t.Errorf("Shard %d was not stopped", i)
+		}
+	}
+
+	if _, err := NewShardedFilter(); err == nil {
+		t.Error("Expected an error for no shards")
+	}
+	if _, err := NewShardedFilter(newFakeFilter(), nil); err == nil {
+		t.Error("Expected an error for a nil shard")
+	}
+}
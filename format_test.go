@@ -0,0 +1,240 @@
+package sbf
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// goldenVectors is the layout of the vectors files in testdata/golden.
+type goldenVectors struct {
+	M               uint32        `json:"m"`
+	K               uint32        `json:"k"`
+	HashAlgorithm   uint8         `json:"hash_algorithm"`
+	Layout          uint8         `json:"layout"`
+	Seeds           []uint64      `json:"seeds"`
+	DecayRate       float64       `json:"decay_rate"`
+	DecayIntervalNs int64         `json:"decay_interval_ns"`
+	Added           []string      `json:"added"` // Hex-encoded elements
+	Probes          []goldenProbe `json:"probes"`
+}
+
+// goldenProbe is an element with its expected bit indexes and Check result.
+type goldenProbe struct {
+	Hex     string   `json:"hex"`
+	Indexes []uint32 `json:"indexes"`
+	Check   bool     `json:"check"`
+}
+
+// goldenCase is a filter configuration stored in testdata/golden, with its vectors file and the
+// golden files of each bit array encoding.
+type goldenCase struct {
+	name      string
+	alg       HashAlgorithm
+	seeds     []uint64
+	layout    Layout
+	vectors   string
+	encodings map[string]byte
+}
+
+var goldenCases = []goldenCase{
+	{
+		name:    "xxh3-seeded",
+		alg:     HashXXH3Seeded,
+		vectors: "vectors.json",
+		encodings: map[string]byte{
+			"filter.raw.sbf":   encodingRaw,
+			"filter.rle.sbf":   encodingRLE,
+			"filter.flate.sbf": encodingFlate,
+		},
+	},
+	{
+		name:      "xxh3-128-double",
+		alg:       HashXXH3Double,
+		seeds:     []uint64{0x9e3779b97f4a7c15},
+		vectors:   "xxh3-128-double.json",
+		encodings: map[string]byte{"xxh3-128-double.raw.sbf": encodingRaw},
+	},
+	{
+		name:      "fnv1a",
+		alg:       HashFNV1a,
+		seeds:     []uint64{11, 22, 33, 44, 55, 66, 77},
+		vectors:   "fnv1a.json",
+		encodings: map[string]byte{"fnv1a.raw.sbf": encodingRaw},
+	},
+	{
+		name:      "murmur3",
+		alg:       HashMurmur3,
+		seeds:     []uint64{0x5eed},
+		vectors:   "murmur3.json",
+		encodings: map[string]byte{"murmur3.raw.sbf": encodingRaw},
+	},
+	{
+		name:      "siphash",
+		alg:       HashSipHash,
+		seeds:     []uint64{0x0706050403020100, 0x0f0e0d0c0b0a0908},
+		vectors:   "siphash.json",
+		encodings: map[string]byte{"siphash.raw.sbf": encodingRaw},
+	},
+	{
+		name:      "partitioned",
+		alg:       HashXXH3Seeded,
+		layout:    LayoutPartitioned,
+		vectors:   "partitioned.json",
+		encodings: map[string]byte{"partitioned.raw.sbf": encodingRaw},
+	},
+}
+
+// goldenFilter builds the filter stored in the golden files of a case.
+func goldenFilter(t *testing.T, c goldenCase) (*StableBloomFilter, [][]byte, [][]byte) {
+	t.Helper()
+
+	sbf, err := newStableBloomFilterWithHash(2048, 7, c.alg, c.seeds, c.layout, 0.01, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	t.Cleanup(sbf.StopDecay)
+
+	added := [][]byte{
+		[]byte("alpha"),
+		[]byte("beta"),
+		[]byte("gamma"),
+		[]byte(""),
+		[]byte("user_123456"),
+		{0x00, 0xff, 0x10, 0x80},
+	}
+	for _, data := range added {
+		sbf.Add(data)
+	}
+	absent := [][]byte{
+		[]byte("delta"),
+		[]byte("Alpha"),
+		[]byte("user_123457"),
+		{0x00},
+	}
+	return sbf, added, absent
+}
+
+func TestGoldenFiles(t *testing.T) {
+	for _, c := range goldenCases {
+		t.Run(c.name, func(t *testing.T) {
+			testGoldenCase(t, c)
+		})
+	}
+}
+
+func testGoldenCase(t *testing.T, c goldenCase) {
+	sbf, added, absent := goldenFilter(t, c)
+	dir := filepath.Join("testdata", "golden")
+
+	vectors := goldenVectors{
+		M:               sbf.m,
+		K:               sbf.k,
+		HashAlgorithm:   uint8(sbf.hashAlg),
+		Layout:          uint8(sbf.layout),
+		Seeds:           sbf.seeds,
+		DecayRate:       sbf.decayRate,
+		DecayIntervalNs: int64(sbf.decayPeriod),
+	}
+	for _, data := range added {
+		vectors.Added = append(vectors.Added, hex.EncodeToString(data))
+	}
+	for _, data := range append(added, absent...) {
+		probe := goldenProbe{Hex: hex.EncodeToString(data), Check: sbf.Check(data)}
+		for i := uint32(0); i < sbf.k; i++ {
+			probe.Indexes = append(probe.Indexes, sbf.hashIndex(data, i))
+		}
+		vectors.Probes = append(vectors.Probes, probe)
+	}
+
+	if *update {
+		encoded, err := json.MarshalIndent(vectors, "", "  ")
+		if err != nil {
+			t.Fatalf("Failed to encode vectors: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, c.vectors), append(encoded, '\n'), 0o644); err != nil {
+			t.Fatalf("Failed to write vectors: %v", err)
+		}
+		for name, encoding := range c.encodings {
+			var buf bytes.Buffer
+			if _, err := sbf.writeWords(&buf, sbf.snapshot(), encoding); err != nil {
+				t.Fatalf("Failed to encode %s: %v", name, err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0o644); err != nil {
+				t.Fatalf("Failed to write %s: %v", name, err)
+			}
+		}
+	}
+
+	// The vectors describe what this implementation computes
+	raw, err := os.ReadFile(filepath.Join(dir, c.vectors))
+	if err != nil {
+		t.Fatalf("Failed to read vectors: %v", err)
+	}
+	var golden goldenVectors
+	if err := json.Unmarshal(raw, &golden); err != nil {
+		t.Fatalf("Failed to decode vectors: %v", err)
+	}
+	want, _ := json.Marshal(golden)
+	got, _ := json.Marshal(vectors)
+	if !bytes.Equal(want, got) {
+		t.Errorf("%s does not match the implementation (run go test -update to regenerate)\nwant %s\ngot  %s", c.vectors, want, got)
+	}
+
+	for name, encoding := range c.encodings {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatalf("Failed to read golden file: %v", err)
+			}
+
+			// Raw and RLE are canonical and must be reproduced byte for byte
+			if encoding != encodingFlate {
+				var buf bytes.Buffer
+				if _, err := sbf.writeWords(&buf, sbf.snapshot(), encoding); err != nil {
+					t.Fatalf("Failed to encode: %v", err)
+				}
+				if !bytes.Equal(buf.Bytes(), data) {
+					t.Errorf("Encoding differs from the golden file")
+				}
+			}
+
+			restored, err := UnmarshalStableBloomFilter(data)
+			if err != nil {
+				t.Fatalf("Failed to decode golden file: %v", err)
+			}
+			defer restored.StopDecay()
+
+			if restored.m != golden.M || restored.k != golden.K || uint8(restored.layout) != golden.Layout {
+				t.Errorf("Expected m=%d k=%d layout=%d, got m=%d k=%d layout=%d",
+					golden.M, golden.K, golden.Layout, restored.m, restored.k, restored.layout)
+			}
+			for i := range sbf.filter {
+				if restored.filter[i] != sbf.filter[i] {
+					t.Fatalf("Word %d differs from the golden filter", i)
+				}
+			}
+			for _, probe := range golden.Probes {
+				element, err := hex.DecodeString(probe.Hex)
+				if err != nil {
+					t.Fatalf("Invalid probe %q: %v", probe.Hex, err)
+				}
+				for i, want := range probe.Indexes {
+					if got := restored.hashIndex(element, uint32(i)); got != want {
+						t.Errorf("Index %d of %s = %d, want %d", i, probe.Hex, got, want)
+					}
+				}
+				if got := restored.Check(element); got != probe.Check {
+					t.Errorf("Check(%s) = %v, want %v", probe.Hex, got, probe.Check)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,328 @@
+package sbf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/dchest/siphash"
+	"github.com/twmb/murmur3"
+	"github.com/zeebo/xxh3"
+)
+
+// HashAlgorithm identifies a named family of hash functions.
+//
+// A filter built from a named algorithm records its ID and seeds when serialized, so it can be
+// restored with identical hash functions. Filters built from a custom []Hash64 have no algorithm
+// and cannot be serialized.
+type HashAlgorithm uint8
+
+// Built-in hash algorithms. Their definitions are part of the serialization format; see FORMAT.md.
+const (
+	HashCustom     HashAlgorithm = 0 // Custom hash functions, not serializable
+	HashXXH3Seeded HashAlgorithm = 1 // xxh3 64-bit hash with one seed per hash function (default)
+	HashXXH3Double HashAlgorithm = 2 // xxh3 128-bit hash with one seed, double hashing
+	HashFNV1a      HashAlgorithm = 3 // FNV-1a 64-bit hash with one seed per hash function
+	HashMurmur3    HashAlgorithm = 4 // MurmurHash3 x64 128-bit with one 32-bit seed, double hashing
+	HashSipHash    HashAlgorithm = 5 // SipHash-2-4 128-bit keyed with two seeds, double hashing
+
+	// HashUserDefined is the first ID available to RegisterHashAlgorithm.
+	HashUserDefined HashAlgorithm = 128
+)
+
+// HashFamily describes how to build the hash functions of a named algorithm from its seeds.
+type HashFamily struct {
+	// Name identifies the algorithm in JSON and configuration files.
+	Name string
+	// NumSeeds returns the number of seeds needed for k hash functions.
+	NumSeeds func(k uint32) int
+	// New returns k hash functions built from the given seeds, or an error if the seeds are invalid.
+	New func(k uint32, seeds []uint64) ([]Hash64, error)
+	// Keyed reports that the seeds are a secret key. Missing seeds are drawn from crypto/rand
+	// instead of defaulting to 0, 1, ..., and seeds are left out of error messages.
+	Keyed bool
+
+	// sum128, if set, returns the 128-bit hash that hash function j derives h1 + j*h2 from, so
+	// filters can hash each element once instead of k times.
+	sum128 func(seeds []uint64) sum128Func
+}
+
+// sum128Func returns the two halves of the 128-bit hash of an element.
+type sum128Func func(data []byte) (h1, h2 uint64)
+
+var (
+	hashRegistryMu sync.RWMutex
+	hashRegistry   = map[HashAlgorithm]HashFamily{
+		HashXXH3Seeded: {Name: "xxh3-seeded", NumSeeds: seedPerHash, New: newXXH3Seeded},
+		HashXXH3Double: {Name: "xxh3-128-double", NumSeeds: fixedSeeds(1), New: newXXH3Double, sum128: xxh3Sum128},
+		HashFNV1a:      {Name: "fnv1a", NumSeeds: seedPerHash, New: newFNV1a},
+		HashMurmur3:    {Name: "murmur3", NumSeeds: fixedSeeds(1), New: newMurmur3, sum128: murmur3Sum128},
+		HashSipHash:    {Name: "siphash", NumSeeds: fixedSeeds(2), New: newSipHash, Keyed: true, sum128: sipHashSum128},
+	}
+)
+
+// RegisterHashAlgorithm registers an application-defined hash algorithm under the given ID.
+//
+// Filters built with the algorithm can then be serialized, and restored by any program that
+// registers the same family under the same ID.
+//
+// Parameters:
+//   - id: ID recorded in serialized filters (HashUserDefined or greater).
+//   - family: Name and constructor of the algorithm.
+//
+// Returns:
+//   - An error if the ID is reserved or the ID or name is already registered.
+func RegisterHashAlgorithm(id HashAlgorithm, family HashFamily) error {
+	if id < HashUserDefined {
+		return fmt.Errorf("hash algorithm ID %d is reserved", id)
+	}
+	if family.Name == "" || family.NumSeeds == nil || family.New == nil {
+		return errors.New("hash family must have a name, NumSeeds and New")
+	}
+
+	hashRegistryMu.Lock()
+	defer hashRegistryMu.Unlock()
+	if _, ok := hashRegistry[id]; ok {
+		return fmt.Errorf("hash algorithm ID %d is already registered", id)
+	}
+	for _, f := range hashRegistry {
+		if f.Name == family.Name {
+			return fmt.Errorf("hash algorithm %q is already registered", family.Name)
+		}
+	}
+	hashRegistry[id] = family
+	return nil
+}
+
+// ParseHashAlgorithm returns the registered algorithm with the given name.
+func ParseHashAlgorithm(name string) (HashAlgorithm, error) {
+	hashRegistryMu.RLock()
+	defer hashRegistryMu.RUnlock()
+	for id, f := range hashRegistry {
+		if f.Name == name {
+			return id, nil
+		}
+	}
+	return HashCustom, fmt.Errorf("unknown hash algorithm %q", name)
+}
+
+// String returns the registered name of the algorithm.
+func (a HashAlgorithm) String() string {
+	if a == HashCustom {
+		return "custom"
+	}
+	if f, ok := lookupHashFamily(a); ok {
+		return f.Name
+	}
+	return fmt.Sprintf("HashAlgorithm(%d)", uint8(a))
+}
+
+// NewStableBloomFilterWithHash creates a new Stable Bloom Filter whose hash functions come from a
+// registered algorithm, so the filter can be serialized and restored.
+//
+// Parameters:
+//   - m: Size of the filter in bits.
+//   - k: Number of hash functions.
+//   - alg: Hash algorithm.
+//   - seeds: Seeds of the algorithm. If nil, the seeds 0, 1, ... are used, or a random key for keyed algorithms.
+//   - decayRate: Probability of decaying bits during each decay interval (between 0 and 1).
+//   - decayInterval: Time duration between decay operations.
+//
+// Returns:
+//   - A pointer to the StableBloomFilter.
+//   - An error if k is 0 or greater than 1024, the algorithm is unknown or the seeds are invalid.
+func NewStableBloomFilterWithHash(m, k uint32, alg HashAlgorithm, seeds []uint64, decayRate float64, decayInterval time.Duration) (*StableBloomFilter, error) {
+	return newStableBloomFilterWithHash(m, k, alg, seeds, LayoutStandard, decayRate, decayInterval)
+}
+
+// newStableBloomFilterWithHash creates a Stable Bloom Filter with the given layout from a registered hash algorithm.
+func newStableBloomFilterWithHash(m, k uint32, alg HashAlgorithm, seeds []uint64, layout Layout, decayRate float64, decayInterval time.Duration) (*StableBloomFilter, error) {
+	if k == 0 {
+		return nil, errors.New("number of hash functions k must be greater than 0")
+	}
+	if k > maxHashFuncs {
+		return nil, fmt.Errorf("number of hash functions k must be at most %d", maxHashFuncs)
+	}
+	if seeds == nil {
+		f, ok := lookupHashFamily(alg)
+		if !ok {
+			return nil, fmt.Errorf("unknown hash algorithm %d", alg)
+		}
+		var err error
+		if seeds, err = defaultSeeds(f, k); err != nil {
+			return nil, err
+		}
+	}
+	hashFuncs, err := buildHashFuncs(alg, k, seeds)
+	if err != nil {
+		return nil, err
+	}
+	return newStableBloomFilter(m, hashFuncs, alg, seeds, layout, decayRate, decayInterval)
+}
+
+// HashAlgorithm returns the filter's hash algorithm and seeds, or HashCustom and nil if it was
+// built with custom hash functions.
+func (sbf *StableBloomFilter) HashAlgorithm() (HashAlgorithm, []uint64) {
+	return sbf.hashAlg, append([]uint64(nil), sbf.seeds...)
+}
+
+// buildSum128 returns the 128-bit hash that the hash functions of a double-hashing algorithm
+// derive from, or nil if the algorithm computes each hash function separately.
+func buildSum128(alg HashAlgorithm, seeds []uint64) sum128Func {
+	f, ok := lookupHashFamily(alg)
+	if !ok || f.sum128 == nil {
+		return nil
+	}
+	return f.sum128(seeds)
+}
+
+// lookupHashFamily returns the registered family of an algorithm.
+func lookupHashFamily(alg HashAlgorithm) (HashFamily, bool) {
+	hashRegistryMu.RLock()
+	defer hashRegistryMu.RUnlock()
+	f, ok := hashRegistry[alg]
+	return f, ok
+}
+
+// buildHashFuncs builds k hash functions of a registered algorithm after checking the seed count.
+func buildHashFuncs(alg HashAlgorithm, k uint32, seeds []uint64) ([]Hash64, error) {
+	f, ok := lookupHashFamily(alg)
+	if !ok {
+		return nil, fmt.Errorf("unknown hash algorithm %d", alg)
+	}
+	if want := f.NumSeeds(k); len(seeds) != want {
+		return nil, fmt.Errorf("hash algorithm %s needs %d seeds for %d hash functions, got %d", f.Name, want, k, len(seeds))
+	}
+	hashFuncs, err := f.New(k, append([]uint64(nil), seeds...))
+	if err != nil {
+		return nil, err
+	}
+	if uint32(len(hashFuncs)) != k {
+		return nil, fmt.Errorf("hash algorithm %s returned %d hash functions, expected %d", f.Name, len(hashFuncs), k)
+	}
+	return hashFuncs, nil
+}
+
+// defaultSeeds returns the seeds used when none are given: a random key for keyed algorithms,
+// otherwise 0, 1, ....
+func defaultSeeds(f HashFamily, k uint32) ([]uint64, error) {
+	seeds := make([]uint64, f.NumSeeds(k))
+	if f.Keyed {
+		return seeds, randomSeeds(seeds)
+	}
+	for i := range seeds {
+		seeds[i] = uint64(i)
+	}
+	return seeds, nil
+}
+
+// seedPerHash is the NumSeeds of algorithms with one seed per hash function.
+func seedPerHash(k uint32) int {
+	return int(k)
+}
+
+// fixedSeeds returns the NumSeeds of algorithms with n seeds regardless of k.
+func fixedSeeds(n int) func(uint32) int {
+	return func(uint32) int { return n }
+}
+
+// newXXH3Seeded builds hash function j as xxh3 with seeds[j].
+func newXXH3Seeded(k uint32, seeds []uint64) ([]Hash64, error) {
+	hashFuncs := make([]Hash64, k)
+	for i := range hashFuncs {
+		hashFuncs[i] = makeHashFunc(seeds[i])
+	}
+	return hashFuncs, nil
+}
+
+// newXXH3Double builds k hash functions from one seeded 128-bit xxh3 hash.
+func newXXH3Double(k uint32, seeds []uint64) ([]Hash64, error) {
+	return doubleHashFuncs(k, xxh3Sum128(seeds)), nil
+}
+
+// xxh3Sum128 returns the 128-bit xxh3 hash seeded with seeds[0].
+func xxh3Sum128(seeds []uint64) sum128Func {
+	seed := seeds[0]
+	return func(data []byte) (uint64, uint64) {
+		sum := xxh3.Hash128Seed(data, seed)
+		return sum.Lo, sum.Hi
+	}
+}
+
+// newFNV1a builds hash function j as FNV-1a over seeds[j] in 8 little-endian bytes followed by the data.
+func newFNV1a(k uint32, seeds []uint64) ([]Hash64, error) {
+	hashFuncs := make([]Hash64, k)
+	for i := range hashFuncs {
+		var prefix [8]byte
+		binary.LittleEndian.PutUint64(prefix[:], seeds[i])
+		basis := fnv1a(fnv1aOffset64, prefix[:])
+		hashFuncs[i] = func(data []byte) uint64 {
+			return fnv1a(basis, data)
+		}
+	}
+	return hashFuncs, nil
+}
+
+// newMurmur3 builds k hash functions from one MurmurHash3 x64 128-bit hash with a 32-bit seed.
+func newMurmur3(k uint32, seeds []uint64) ([]Hash64, error) {
+	seed := seeds[0]
+	if seed > math.MaxUint32 {
+		return nil, fmt.Errorf("murmur3 seed %d does not fit in 32 bits", seed)
+	}
+	return doubleHashFuncs(k, murmur3Sum128(seeds)), nil
+}
+
+// murmur3Sum128 returns the MurmurHash3 x64 128-bit hash with the 32-bit seed seeds[0].
+func murmur3Sum128(seeds []uint64) sum128Func {
+	seed := seeds[0]
+	return func(data []byte) (uint64, uint64) {
+		return murmur3.SeedSum128(seed, seed, data)
+	}
+}
+
+// newSipHash builds k hash functions from one SipHash-2-4 128-bit hash keyed with the two seeds.
+func newSipHash(k uint32, seeds []uint64) ([]Hash64, error) {
+	return doubleHashFuncs(k, sipHashSum128(seeds)), nil
+}
+
+// sipHashSum128 returns the SipHash-2-4 128-bit hash keyed with seeds[0] and seeds[1].
+func sipHashSum128(seeds []uint64) sum128Func {
+	k0, k1 := seeds[0], seeds[1]
+	return func(data []byte) (uint64, uint64) {
+		return siphash.Hash128(k0, k1, data)
+	}
+}
+
+// doubleHashFuncs derives k hash functions h1 + j*h2 from a 128-bit hash (Kirsch–Mitzenmacher).
+//
+// Each function computes the full 128-bit hash. StableBloomFilter avoids this by computing it once
+// per element through HashFamily.sum128.
+func doubleHashFuncs(k uint32, sum128 sum128Func) []Hash64 {
+	hashFuncs := make([]Hash64, k)
+	for i := range hashFuncs {
+		j := uint64(i)
+		hashFuncs[i] = func(data []byte) uint64 {
+			h1, h2 := sum128(data)
+			return h1 + j*h2
+		}
+	}
+	return hashFuncs
+}
+
+// FNV-1a 64-bit parameters.
+const (
+	fnv1aOffset64 = 14695981039346656037
+	fnv1aPrime64  = 1099511628211
+)
+
+// fnv1a continues an FNV-1a 64-bit hash from state h over data.
+func fnv1a(h uint64, data []byte) uint64 {
+	for _, b := range data {
+		h ^= uint64(b)
+		h *= fnv1aPrime64
+	}
+	return h
+}
@@ -0,0 +1,208 @@
+package sbf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestHashAlgorithms(t *testing.T) {
+	algorithms := []HashAlgorithm{HashXXH3Seeded, HashXXH3Double, HashFNV1a, HashMurmur3, HashSipHash}
+
+	for _, alg := range algorithms {
+		t.Run(alg.String(), func(t *testing.T) {
+			sbf, err := NewStableBloomFilterWithHash(4096, 5, alg, nil, 0.0, time.Hour)
+			if err != nil {
+				t.Fatalf("Failed to create StableBloomFilter: %v", err)
+			}
+			defer sbf.StopDecay()
+
+			for i := 0; i < 100; i++ {
+				sbf.Add([]byte(fmt.Sprintf("element_%d", i)))
+			}
+
+			data, err := sbf.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary failed: %v", err)
+			}
+			restored, err := UnmarshalStableBloomFilter(data)
+			if err != nil {
+				t.Fatalf("UnmarshalStableBloomFilter failed: %v", err)
+			}
+			defer restored.StopDecay()
+
+			gotAlg, gotSeeds := restored.HashAlgorithm()
+			if gotAlg != alg {
+				t.Errorf("Expected algorithm %v, got %v", alg, gotAlg)
+			}
+			_, wantSeeds := sbf.HashAlgorithm()
+			if fmt.Sprint(gotSeeds) != fmt.Sprint(wantSeeds) {
+				t.Errorf("Expected seeds %v, got %v", wantSeeds, gotSeeds)
+			}
+			for i := 0; i < 1000; i++ {
+				data := []byte(fmt.Sprintf("probe_%d", i))
+				if restored.hashIndex(data, uint32(i%5)) != sbf.hashIndex(data, uint32(i%5)) {
+					t.Fatalf("Restored filter hashes %q differently", data)
+				}
+				// Double hashing computes the 128-bit hash once, which must match the hash functions
+				if want := sbf.hashFuncs[i%5](data) % uint64(sbf.m); uint64(sbf.hashIndex(data, uint32(i%5))) != want {
+					t.Fatalf("Index of %q does not match hash function %d", data, i%5)
+				}
+			}
+			if err := restored.Merge(sbf); err != nil {
+				t.Errorf("Restored filter should be compatible: %v", err)
+			}
+		})
+	}
+}
+
+func TestHashAlgorithmsDiffer(t *testing.T) {
+	seen := make(map[uint64]HashAlgorithm)
+	for _, alg := range []HashAlgorithm{HashXXH3Seeded, HashXXH3Double, HashFNV1a, HashMurmur3, HashSipHash} {
+		hashFuncs, err := buildHashFuncs(alg, 2, make([]uint64, mustFamily(t, alg).NumSeeds(2)))
+		if err != nil {
+			t.Fatalf("buildHashFuncs(%v) failed: %v", alg, err)
+		}
+		sum := hashFuncs[1]([]byte("element"))
+		if other, ok := seen[sum]; ok {
+			t.Errorf("%v and %v produce the same hash", alg, other)
+		}
+		seen[sum] = alg
+	}
+}
+
+func TestFNV1aMatchesReference(t *testing.T) {
+	// FNV-1a 64 of the empty string is the offset basis, and of "a" is 0xaf63dc4c8601ec8c
+	if got := fnv1a(fnv1aOffset64, []byte("a")); got != 0xaf63dc4c8601ec8c {
+		t.Errorf("fnv1a(\"a\") = %#x", got)
+	}
+}
+
+func TestNewStableBloomFilterWithHashErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		k     uint32
+		alg   HashAlgorithm
+		seeds []uint64
+	}{
+		{name: "zero k", k: 0, alg: HashXXH3Seeded},
+		{name: "huge k", k: 1 << 28, alg: HashSipHash},
+		{name: "unknown algorithm", k: 3, alg: 42},
+		{name: "custom algorithm", k: 3, alg: HashCustom},
+		{name: "wrong seed count", k: 3, alg: HashSipHash, seeds: []uint64{1}},
+		{name: "murmur3 seed too large", k: 3, alg: HashMurmur3, seeds: []uint64{1 << 40}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sbf, err := NewStableBloomFilterWithHash(1024, tt.k, tt.alg, tt.seeds, 0.01, time.Hour)
+			if err == nil {
+				sbf.StopDecay()
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+// unregisterHashAlgorithm removes an algorithm registered by a test, so the test can run again.
+func unregisterHashAlgorithm(id HashAlgorithm) {
+	hashRegistryMu.Lock()
+	defer hashRegistryMu.Unlock()
+	delete(hashRegistry, id)
+}
+
+func TestRegisterHashAlgorithm(t *testing.T) {
+	family := HashFamily{
+		Name:     "test-xor",
+		NumSeeds: func(uint32) int { return 1 },
+		New: func(k uint32, seeds []uint64) ([]Hash64, error) {
+			hashFuncs := make([]Hash64, k)
+			for i := range hashFuncs {
+				salt := seeds[0] + uint64(i)
+				hashFuncs[i] = func(data []byte) uint64 {
+					return fnv1a(fnv1aOffset64, data) ^ salt*0x9e3779b97f4a7c15
+				}
+			}
+			return hashFuncs, nil
+		},
+	}
+
+	if err := RegisterHashAlgorithm(HashSipHash, family); err == nil {
+		t.Error("Expected an error registering a reserved ID")
+	}
+	id := HashUserDefined + 7
+	if err := RegisterHashAlgorithm(id, family); err != nil {
+		t.Fatalf("RegisterHashAlgorithm failed: %v", err)
+	}
+	t.Cleanup(func() { unregisterHashAlgorithm(id) })
+	if err := RegisterHashAlgorithm(id+1, family); err == nil {
+		t.Error("Expected an error registering a duplicate name")
+	}
+	if parsed, err := ParseHashAlgorithm("test-xor"); err != nil || parsed != id {
+		t.Errorf("ParseHashAlgorithm returned %v, %v", parsed, err)
+	}
+
+	sbf, err := NewStableBloomFilterWithHash(1024, 3, id, []uint64{99}, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+	sbf.Add([]byte("element"))
+
+	encoded, err := json.Marshal(sbf)
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+	var restored StableBloomFilter
+	if err := json.Unmarshal(encoded, &restored); err != nil {
+		t.Fatalf("UnmarshalJSON failed: %v", err)
+	}
+	defer restored.StopDecay()
+	if !restored.Check([]byte("element")) || restored.Config().HashAlgorithm != "test-xor" {
+		t.Error("Filter with a registered algorithm did not round trip")
+	}
+}
+
+func TestCustomHashNotSerializable(t *testing.T) {
+	sbf, err := NewStableBloomFilter(1024, []Hash64{makeHashFunc(1)}, 0.01, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+
+	if alg, seeds := sbf.HashAlgorithm(); alg != HashCustom || seeds != nil {
+		t.Errorf("Expected HashCustom with no seeds, got %v %v", alg, seeds)
+	}
+	if _, err := sbf.MarshalBinary(); !errors.Is(err, ErrCustomHash) {
+		t.Errorf("Expected ErrCustomHash, got %v", err)
+	}
+}
+
+func TestHashAlgorithmIncompatible(t *testing.T) {
+	a, err := NewStableBloomFilterWithHash(1024, 3, HashFNV1a, nil, 0.01, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer a.StopDecay()
+	b, err := NewStableBloomFilterWithHash(1024, 3, HashXXH3Seeded, nil, 0.01, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer b.StopDecay()
+
+	var incompatible *IncompatibleError
+	if err := a.Merge(b); !errors.As(err, &incompatible) || incompatible.Param != "hash" {
+		t.Errorf("Expected a hash IncompatibleError, got %v", err)
+	}
+}
+
+func mustFamily(t *testing.T, alg HashAlgorithm) HashFamily {
+	t.Helper()
+	f, ok := lookupHashFamily(alg)
+	if !ok {
+		t.Fatalf("Hash algorithm %v is not registered", alg)
+	}
+	return f
+}
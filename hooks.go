@@ -0,0 +1,159 @@
+package sbf
+
+import "time"
+
+// DecayEvent describes a completed decay pass.
+type DecayEvent struct {
+	Duration time.Duration // Time taken by the decay pass
+	Cleared  uint64        // Number of bits cleared by the decay pass
+}
+
+// OnDecay registers fn to be called after each decay pass.
+//
+// Callbacks run synchronously on the decay goroutine, so they should return quickly. They must not
+// call StopDecay, which waits for the decay goroutine and would deadlock; start a goroutine to call
+// it instead.
+//
+// Returns:
+//   - A function that unregisters fn. It is safe to call more than once.
+func (sbf *StableBloomFilter) OnDecay(fn func(DecayEvent)) (cancel func()) {
+	sbf.listenersMu.Lock()
+	defer sbf.listenersMu.Unlock()
+
+	if sbf.listeners == nil {
+		sbf.listeners = make(map[uint64]func(DecayEvent))
+	}
+	id := sbf.nextListener
+	sbf.nextListener++
+	sbf.listeners[id] = fn
+
+	return func() {
+		sbf.listenersMu.Lock()
+		delete(sbf.listeners, id)
+		sbf.listenersMu.Unlock()
+	}
+}
+
+// notifyDecay runs the registered decay callbacks.
+func (sbf *StableBloomFilter) notifyDecay(event DecayEvent) {
+	sbf.listenersMu.Lock()
+	fns := make([]func(DecayEvent), 0, len(sbf.listeners))
+	for _, fn := range sbf.listeners {
+		fns = append(fns, fn)
+	}
+	sbf.listenersMu.Unlock()
+
+	for _, fn := range fns {
+		fn(event)
+	}
+}
+
+// threshold is a callback fired when a statistic rises above a limit.
+type threshold struct {
+	value func(Stats) float64
+	limit float64
+	fn    func(Stats)
+	above bool // Whether the statistic was above the limit at the last evaluation
+}
+
+// OnFillRatioAbove registers fn to be called when the filter's fill ratio rises above limit.
+//
+// Thresholds are evaluated after each decay pass and, if enabled with SetThresholdCheckInterval,
+// every N calls to Add. fn is called once when the fill ratio crosses the limit and is re-armed
+// once the fill ratio drops back to or below it, so a saturated filter does not fire on every pass.
+//
+// fn runs synchronously on the goroutine evaluating the thresholds, either the decay goroutine or
+// one calling Add. It must not call StopDecay, which waits for any evaluation in progress and would
+// deadlock; to stop decay from fn, start a goroutine that calls StopDecay.
+//
+// Returns:
+//   - A function that unregisters fn. It is safe to call more than once.
+func (sbf *StableBloomFilter) OnFillRatioAbove(limit float64, fn func(Stats)) (cancel func()) {
+	return sbf.addThreshold(&threshold{
+		value: func(s Stats) float64 { return s.FillRatio },
+		limit: limit,
+		fn:    fn,
+	})
+}
+
+// OnEstimatedFPRAbove registers fn to be called when the filter's estimated false positive rate rises above limit.
+//
+// It is evaluated and re-armed like OnFillRatioAbove, and fn must not call StopDecay either.
+//
+// Returns:
+//   - A function that unregisters fn. It is safe to call more than once.
+func (sbf *StableBloomFilter) OnEstimatedFPRAbove(limit float64, fn func(Stats)) (cancel func()) {
+	return sbf.addThreshold(&threshold{
+		value: func(s Stats) float64 { return s.EstimatedFPR },
+		limit: limit,
+		fn:    fn,
+	})
+}
+
+// SetThresholdCheckInterval makes Add evaluate the registered thresholds every n calls, in addition to after each decay pass.
+//
+// Evaluation scans the whole filter and runs callbacks on the goroutine calling Add, so n should be
+// large relative to the filter size. An n of 0 disables evaluation from Add, which is the default.
+func (sbf *StableBloomFilter) SetThresholdCheckInterval(n uint64) {
+	sbf.thresholdEvery.Store(n)
+}
+
+// addThreshold registers a threshold hook.
+func (sbf *StableBloomFilter) addThreshold(t *threshold) func() {
+	sbf.listenersMu.Lock()
+	defer sbf.listenersMu.Unlock()
+
+	if sbf.thresholds == nil {
+		sbf.thresholds = make(map[uint64]*threshold)
+	}
+	id := sbf.nextListener
+	sbf.nextListener++
+	sbf.thresholds[id] = t
+
+	return func() {
+		sbf.listenersMu.Lock()
+		delete(sbf.thresholds, id)
+		sbf.listenersMu.Unlock()
+	}
+}
+
+// evaluateThresholds checks the registered thresholds and fires those that were crossed.
+func (sbf *StableBloomFilter) evaluateThresholds() {
+	sbf.evalMu.Lock()
+	defer sbf.evalMu.Unlock()
+	sbf.evaluateThresholdsLocked()
+}
+
+// tryEvaluateThresholds evaluates thresholds unless an evaluation is already running.
+//
+// It keeps Add from queueing behind a concurrent evaluation.
+func (sbf *StableBloomFilter) tryEvaluateThresholds() {
+	if !sbf.evalMu.TryLock() {
+		return
+	}
+	defer sbf.evalMu.Unlock()
+	sbf.evaluateThresholdsLocked()
+}
+
+// evaluateThresholdsLocked does the work of evaluateThresholds. evalMu must be held.
+func (sbf *StableBloomFilter) evaluateThresholdsLocked() {
+	sbf.listenersMu.Lock()
+	ts := make([]*threshold, 0, len(sbf.thresholds))
+	for _, t := range sbf.thresholds {
+		ts = append(ts, t)
+	}
+	sbf.listenersMu.Unlock()
+
+	if len(ts) == 0 {
+		return
+	}
+
+	stats := sbf.Stats()
+	for _, t := range ts {
+		above := t.value(stats) > t.limit
+		if above && !t.above {
+			t.fn(stats)
+		}
+		t.above = above
+	}
+}
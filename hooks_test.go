@@ -0,0 +1,143 @@
+package sbf
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOnDecay(t *testing.T) {
+	sbf, err := NewStableBloomFilter(1024, nil, 1.0, time.Millisecond*5)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+
+	events := make(chan DecayEvent, 1)
+	cancel := sbf.OnDecay(func(e DecayEvent) {
+		select {
+		case events <- e:
+		default:
+		}
+	})
+
+	select {
+	case e := <-events:
+		if e.Duration <= 0 {
+			t.Errorf("Expected a positive decay duration, got %v", e.Duration)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnDecay callback was not called")
+	}
+
+	cancel()
+	cancel() // Must be safe to call twice
+
+	sbf.listenersMu.Lock()
+	n := len(sbf.listeners)
+	sbf.listenersMu.Unlock()
+	if n != 0 {
+		t.Errorf("Expected no listeners after cancel, got %d", n)
+	}
+}
+
+func TestOnFillRatioAboveFromAdd(t *testing.T) {
+	sbf, err := NewStableBloomFilter(1024, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+
+	var fired []Stats
+	sbf.OnFillRatioAbove(0.5, func(s Stats) {
+		fired = append(fired, s)
+	})
+	sbf.SetThresholdCheckInterval(10)
+
+	for i := 0; i < 1000; i++ {
+		sbf.Add([]byte(fmt.Sprintf("element%d", i)))
+	}
+
+	if len(fired) != 1 {
+		t.Fatalf("Expected the hook to fire once, fired %d times", len(fired))
+	}
+	if fired[0].FillRatio <= 0.5 {
+		t.Errorf("Hook fired with fill ratio %f", fired[0].FillRatio)
+	}
+}
+
+func TestThresholdRearmsAfterDecay(t *testing.T) {
+	sbf, err := NewStableBloomFilter(1024, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+
+	fired := 0
+	cancel := sbf.OnEstimatedFPRAbove(0.5, func(Stats) {
+		fired++
+	})
+
+	fill := func() {
+		for i := range sbf.filter {
+			atomic.StoreUint64(&sbf.filter[i], ^uint64(0))
+		}
+	}
+
+	fill()
+	sbf.decay() // Decay rate 0 leaves the filter saturated
+	sbf.decay()
+	if fired != 1 {
+		t.Fatalf("Expected the hook to fire once while saturated, fired %d times", fired)
+	}
+
+	sbf.decayRate = 1.0
+	sbf.decay() // Clears everything and re-arms the hook
+	sbf.decayRate = 0.0
+	fill()
+	sbf.decay()
+	if fired != 2 {
+		t.Errorf("Expected the hook to fire again after re-arming, fired %d times", fired)
+	}
+
+	cancel()
+	sbf.decayRate = 1.0
+	sbf.decay()
+	sbf.decayRate = 0.0
+	fill()
+	sbf.decay()
+	if fired != 2 {
+		t.Errorf("Expected no calls after cancel, fired %d times", fired)
+	}
+}
+
+func TestStopDecayFromThresholdHook(t *testing.T) {
+	sbf, err := NewStableBloomFilter(1024, nil, 0.0, time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+
+	stopped := make(chan struct{})
+	sbf.OnFillRatioAbove(0.0, func(Stats) {
+		// StopDecay waits for this hook, so it must run on another goroutine
+		go func() {
+			sbf.StopDecay()
+			close(stopped)
+		}()
+	})
+	sbf.Add([]byte("element"))
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("StopDecay did not return")
+	}
+
+	runs := sbf.Stats().DecayRuns
+	time.Sleep(5 * time.Millisecond)
+	if got := sbf.Stats().DecayRuns; got != runs {
+		t.Errorf("Expected no decay after StopDecay, got %d more runs", got-runs)
+	}
+}
@@ -0,0 +1,68 @@
+package sbf
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// NewKeyedStableBloomFilter creates a new Stable Bloom Filter whose hash functions are keyed with a
+// secret 128-bit key drawn from crypto/rand.
+//
+// The default hash functions use fixed seeds, so anyone who knows the library can compute which
+// bits an element sets and craft elements that collide or saturate chosen bits. With a secret key
+// the bit positions are unpredictable to an attacker. The filter uses SipHash-2-4 (HashSipHash).
+//
+// The key is stored in serialized filters, so snapshots must be protected like the key itself.
+// Replicas that are merged or synced must share the key; create them with HashKey and
+// NewStableBloomFilterWithHash.
+//
+// Parameters:
+//   - m: Size of the filter in bits.
+//   - k: Number of hash functions.
+//   - decayRate: Probability of decaying bits during each decay interval (between 0 and 1).
+//   - decayInterval: Time duration between decay operations.
+//
+// Returns:
+//   - A pointer to the StableBloomFilter.
+//   - An error if the key cannot be generated or initialization fails.
+func NewKeyedStableBloomFilter(m, k uint32, decayRate float64, decayInterval time.Duration) (*StableBloomFilter, error) {
+	return NewStableBloomFilterWithHash(m, k, HashSipHash, nil, decayRate, decayInterval)
+}
+
+// NewDefaultKeyedStableBloomFilter creates a new keyed Stable Bloom Filter with optimal settings
+// based on expected items and desired false positive rate.
+//
+// See NewKeyedStableBloomFilter and NewDefaultStableBloomFilter.
+func NewDefaultKeyedStableBloomFilter(expectedItems uint32, falsePositiveRate float64, decayRate float64, decayInterval time.Duration) (*StableBloomFilter, error) {
+	return NewStableBloomFilterFromConfig(Config{
+		ExpectedItems:     expectedItems,
+		FalsePositiveRate: falsePositiveRate,
+		DecayRate:         decayRate,
+		DecayInterval:     Duration(decayInterval),
+		HashAlgorithm:     HashSipHash.String(),
+	})
+}
+
+// HashKey returns the filter's secret hash key, or nil if its hash algorithm is not keyed.
+//
+// Pass it as the seeds of NewStableBloomFilterWithHash to create replicas that hash identically.
+func (sbf *StableBloomFilter) HashKey() []uint64 {
+	if f, ok := lookupHashFamily(sbf.hashAlg); !ok || !f.Keyed {
+		return nil
+	}
+	return append([]uint64(nil), sbf.seeds...)
+}
+
+// randomSeeds fills seeds from crypto/rand.
+func randomSeeds(seeds []uint64) error {
+	buf := make([]byte, 8*len(seeds))
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Errorf("generating hash key: %w", err)
+	}
+	for i := range seeds {
+		seeds[i] = binary.LittleEndian.Uint64(buf[8*i:])
+	}
+	return nil
+}
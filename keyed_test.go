@@ -0,0 +1,98 @@
+package sbf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyedStableBloomFilter(t *testing.T) {
+	a, err := NewKeyedStableBloomFilter(4096, 5, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer a.StopDecay()
+	b, err := NewKeyedStableBloomFilter(4096, 5, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer b.StopDecay()
+
+	keyA, keyB := a.HashKey(), b.HashKey()
+	if len(keyA) != 2 || fmt.Sprint(keyA) == fmt.Sprint(keyB) {
+		t.Fatalf("Expected distinct random 128-bit keys, got %v and %v", keyA, keyB)
+	}
+
+	// Independent keys place the same element on different bits
+	differ := false
+	for i := 0; i < 100 && !differ; i++ {
+		data := []byte(fmt.Sprintf("element_%d", i))
+		differ = a.hashIndex(data, 0) != b.hashIndex(data, 0)
+	}
+	if !differ {
+		t.Error("Filters with different keys hash identically")
+	}
+
+	// Filters with different keys cannot be merged, and the error does not leak the keys
+	err = a.Merge(b)
+	if !errors.Is(err, ErrIncompatible) {
+		t.Fatalf("Expected ErrIncompatible, got %v", err)
+	}
+	if strings.Contains(err.Error(), fmt.Sprint(keyA[0])) {
+		t.Errorf("Error message leaks the hash key: %v", err)
+	}
+
+	// A replica sharing the key is compatible
+	replica, err := NewStableBloomFilterWithHash(4096, 5, HashSipHash, keyA, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer replica.StopDecay()
+	replica.Add([]byte("shared"))
+	if err := a.Merge(replica); err != nil {
+		t.Fatalf("Merge with a replica sharing the key failed: %v", err)
+	}
+	if !a.Check([]byte("shared")) {
+		t.Error("Merged element missing")
+	}
+}
+
+func TestKeyedStableBloomFilterPersistsKey(t *testing.T) {
+	sbf, err := NewDefaultKeyedStableBloomFilter(1000, 0.01, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+	sbf.Add([]byte("element"))
+
+	data, err := sbf.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	restored, err := UnmarshalStableBloomFilter(data)
+	if err != nil {
+		t.Fatalf("UnmarshalStableBloomFilter failed: %v", err)
+	}
+	defer restored.StopDecay()
+
+	if fmt.Sprint(restored.HashKey()) != fmt.Sprint(sbf.HashKey()) {
+		t.Errorf("Key changed after round trip: %v vs %v", restored.HashKey(), sbf.HashKey())
+	}
+	if !restored.Check([]byte("element")) {
+		t.Error("Element missing after round trip")
+	}
+}
+
+func TestHashKeyUnkeyed(t *testing.T) {
+	sbf, err := NewStableBloomFilter(1024, nil, 0.01, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+
+	if key := sbf.HashKey(); key != nil {
+		t.Errorf("Expected no key for an unkeyed filter, got %v", key)
+	}
+}
@@ -0,0 +1,77 @@
+package sbf
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Layout describes how a Stable Bloom Filter's hash functions map elements to bits.
+type Layout uint8
+
+const (
+	// LayoutStandard lets every hash function index the whole bit array.
+	LayoutStandard Layout = 0
+	// LayoutPartitioned divides the bit array into k equal partitions, and hash function i only
+	// indexes partition i. Each element sets exactly one bit per partition, which makes per-hash
+	// collisions independent, and partitions are decayed in parallel.
+	LayoutPartitioned Layout = 1
+)
+
+// String returns the name of the layout.
+func (l Layout) String() string {
+	switch l {
+	case LayoutStandard:
+		return "standard"
+	case LayoutPartitioned:
+		return "partitioned"
+	default:
+		return fmt.Sprintf("Layout(%d)", uint8(l))
+	}
+}
+
+// ParseLayout returns the layout with the given name.
+//
+// The empty string is LayoutStandard.
+func ParseLayout(name string) (Layout, error) {
+	switch name {
+	case "", "standard":
+		return LayoutStandard, nil
+	case "partitioned":
+		return LayoutPartitioned, nil
+	default:
+		return LayoutStandard, fmt.Errorf("unknown layout %q", name)
+	}
+}
+
+// NewPartitionedStableBloomFilter creates a new Stable Bloom Filter with LayoutPartitioned.
+//
+// If hashFuncs is empty, it uses default hash functions based on zeebo/xxh3. m is rounded up to a
+// multiple of 64·k so every partition holds whole words.
+//
+// Parameters:
+//   - m: Size of the filter in bits.
+//   - hashFuncs: Slice of hash functions to use. If empty, default hash functions are used.
+//   - decayRate: Probability of decaying bits during each decay interval (between 0 and 1).
+//   - decayInterval: Time duration between decay operations.
+//
+// Returns:
+//   - A pointer to the StableBloomFilter.
+//   - An error if initialization fails.
+func NewPartitionedStableBloomFilter(m uint32, hashFuncs []Hash64, decayRate float64, decayInterval time.Duration) (*StableBloomFilter, error) {
+	if m == 0 {
+		return nil, errors.New("filter size m must be greater than 0")
+	}
+	alg := HashCustom
+	var seeds []uint64
+	if len(hashFuncs) == 0 {
+		hashFuncs, seeds = defaultHashFuncs(7)
+		alg = HashXXH3Seeded
+	}
+	return newStableBloomFilter(m, hashFuncs, alg, seeds, LayoutPartitioned, decayRate, decayInterval)
+}
+
+// Layout returns the filter's layout.
+func (sbf *StableBloomFilter) Layout() Layout {
+	return sbf.layout
+}
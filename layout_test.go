@@ -0,0 +1,168 @@
+package sbf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"testing"
+	"time"
+)
+
+func TestPartitionedLayoutIndexes(t *testing.T) {
+	sbf, err := NewPartitionedStableBloomFilter(1000, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+
+	if sbf.Layout() != LayoutPartitioned {
+		t.Fatalf("Expected LayoutPartitioned, got %v", sbf.Layout())
+	}
+	if sbf.m%(64*sbf.k) != 0 {
+		t.Fatalf("Size %d is not a multiple of 64·k for k=%d", sbf.m, sbf.k)
+	}
+
+	for n := 0; n < 1000; n++ {
+		data := []byte(fmt.Sprintf("element_%d", n))
+		for i := uint32(0); i < sbf.k; i++ {
+			if idx := sbf.hashIndex(data, i); idx/sbf.partSize != i {
+				t.Fatalf("Hash %d of %q indexes partition %d", i, data, idx/sbf.partSize)
+			}
+		}
+	}
+}
+
+func TestPartitionedLayoutAddCheck(t *testing.T) {
+	sbf, err := NewPartitionedStableBloomFilter(1<<14, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+
+	data := []byte("element")
+	sbf.Add(data)
+	if !sbf.Check(data) {
+		t.Error("Element should be present after Add")
+	}
+
+	// One bit is set per partition
+	wordsPerPart := sbf.partSize / 64
+	for p := uint32(0); p < sbf.k; p++ {
+		var set int
+		for _, word := range sbf.filter[p*wordsPerPart : (p+1)*wordsPerPart] {
+			set += bits.OnesCount64(word)
+		}
+		if set != 1 {
+			t.Errorf("Partition %d has %d bits set, expected 1", p, set)
+		}
+	}
+}
+
+func TestPartitionedLayoutFalsePositiveRate(t *testing.T) {
+	sbf, err := NewPartitionedStableBloomFilter(1<<16, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+
+	for i := 0; i < 5000; i++ {
+		sbf.Add([]byte(fmt.Sprintf("element_%d", i)))
+	}
+
+	// Every partition receives one bit per element, so fill_i ≈ 1 - e^(-n/(m/k))
+	expected := math.Pow(1-math.Exp(-5000/float64(sbf.partSize)), float64(sbf.k))
+	estimated := sbf.EstimateFalsePositiveRate()
+	if math.Abs(estimated-expected) > expected*0.2 {
+		t.Errorf("Estimated false positive rate %g, expected about %g", estimated, expected)
+	}
+	if stats := sbf.Stats(); stats.EstimatedFPR != estimated {
+		t.Errorf("Stats reports %g, EstimateFalsePositiveRate %g", stats.EstimatedFPR, estimated)
+	}
+
+	falsePositives := 0
+	const trials = 100000
+	for i := 0; i < trials; i++ {
+		if sbf.Check([]byte(fmt.Sprintf("absent_%d", i))) {
+			falsePositives++
+		}
+	}
+	if observed := float64(falsePositives) / trials; observed > 2*estimated+0.001 {
+		t.Errorf("Observed false positive rate %f far above estimate %f", observed, estimated)
+	}
+}
+
+func TestPartitionedLayoutDecay(t *testing.T) {
+	sbf, err := NewPartitionedStableBloomFilter(1<<14, nil, 1.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+
+	for i := 0; i < 1000; i++ {
+		sbf.Add([]byte(fmt.Sprintf("element_%d", i)))
+	}
+	sbf.decay()
+	if set := sbf.countBitsSet(); set != 0 {
+		t.Errorf("Expected a decay rate of 1 to clear every partition, %d bits left", set)
+	}
+}
+
+func TestPartitionedLayoutPersistence(t *testing.T) {
+	sbf, err := NewStableBloomFilterFromConfig(Config{
+		ExpectedItems:     1000,
+		FalsePositiveRate: 0.01,
+		DecayInterval:     Duration(time.Hour),
+		Layout:            "partitioned",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+	sbf.Add([]byte("element"))
+
+	if cfg := sbf.Config(); cfg.Layout != "partitioned" {
+		t.Errorf("Expected Config layout partitioned, got %q", cfg.Layout)
+	}
+
+	data, err := sbf.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	if data[6] != byte(LayoutPartitioned) {
+		t.Errorf("Expected layout byte %d, got %d", LayoutPartitioned, data[6])
+	}
+	restored, err := UnmarshalStableBloomFilter(data)
+	if err != nil {
+		t.Fatalf("UnmarshalStableBloomFilter failed: %v", err)
+	}
+	defer restored.StopDecay()
+	if restored.Layout() != LayoutPartitioned || !restored.Check([]byte("element")) {
+		t.Error("Restored filter lost its layout or contents")
+	}
+
+	standard, err := NewStableBloomFilterWithHash(sbf.m, sbf.k, HashXXH3Seeded, nil, 0.01, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer standard.StopDecay()
+	var incompatible *IncompatibleError
+	if err := sbf.Merge(standard); !errors.As(err, &incompatible) || incompatible.Param != "layout" {
+		t.Errorf("Expected a layout IncompatibleError, got %v", err)
+	}
+	if err := sbf.Merge(restored); err != nil {
+		t.Errorf("Unexpected error merging partitioned filters: %v", err)
+	}
+}
+
+func TestParseLayout(t *testing.T) {
+	for _, l := range []Layout{LayoutStandard, LayoutPartitioned} {
+		parsed, err := ParseLayout(l.String())
+		if err != nil || parsed != l {
+			t.Errorf("ParseLayout(%q) = %v, %v", l.String(), parsed, err)
+		}
+	}
+	if _, err := ParseLayout("striped"); err == nil {
+		t.Error("Expected an error for an unknown layout")
+	}
+}
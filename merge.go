@@ -0,0 +1,190 @@
+package sbf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"sync/atomic"
+)
+
+// ErrIncompatible is the error matched by errors.Is for any IncompatibleError.
+var ErrIncompatible = errors.New("incompatible filters")
+
+// IncompatibleError reports why two filters cannot be combined.
+type IncompatibleError struct {
+	Param string // Name of the mismatched parameter ("m", "k", "layout" or "hash")
+	This  string // Value in the receiving filter
+	Other string // Value in the other filter
+}
+
+// Error implements the error interface.
+func (e *IncompatibleError) Error() string {
+	return fmt.Sprintf("incompatible filters: %s differs (%s vs %s)", e.Param, e.This, e.Other)
+}
+
+// Unwrap makes errors.Is(err, ErrIncompatible) match.
+func (e *IncompatibleError) Unwrap() error {
+	return ErrIncompatible
+}
+
+// Merge folds the elements of other into the Stable Bloom Filter with a bitwise OR.
+//
+// Both filters must have the same size, number of hash functions, layout, hash algorithm and seeds. Filters built
+// with custom hash functions are only compatible if they were created from the same hashFuncs slice.
+// other is not modified. Merge is safe to call while both filters are in use: Add, decay, Merge
+// and ApplyDelta all update words with atomic read-modify-write operations, so no bits are lost.
+//
+// Returns:
+//   - An *IncompatibleError if the filters cannot be merged.
+func (sbf *StableBloomFilter) Merge(other *StableBloomFilter) error {
+	if err := sbf.compatible(other); err != nil {
+		return err
+	}
+	for i := range sbf.filter {
+		if atomicOr(&sbf.filter[i], atomic.LoadUint64(&other.filter[i])) {
+			sbf.markChanged(uint32(i))
+		}
+	}
+	return nil
+}
+
+// Union creates a new Stable Bloom Filter holding the elements of both a and b.
+//
+// The new filter takes its parameters, including decay settings, from a and runs its own decay
+// process, so StopDecay must be called on it when it is no longer needed.
+//
+// Returns:
+//   - A pointer to the new StableBloomFilter.
+//   - An *IncompatibleError if the filters cannot be merged.
+func Union(a, b *StableBloomFilter) (*StableBloomFilter, error) {
+	if err := a.compatible(b); err != nil {
+		return nil, err
+	}
+
+	u, err := newStableBloomFilter(a.m, a.hashFuncs, a.hashAlg, a.seeds, a.layout, a.decayRate, a.decayPeriod)
+	if err != nil {
+		return nil, err
+	}
+	for i := range u.filter {
+		u.filter[i] = atomic.LoadUint64(&a.filter[i]) | atomic.LoadUint64(&b.filter[i])
+	}
+	return u, nil
+}
+
+// Intersect keeps only the bits set in both the Stable Bloom Filter and other, using a bitwise AND.
+//
+// The result reports every element of the intersection, but may also report elements of either
+// filter whose bits happen to be set in both, so its false positive rate is higher than that of a
+// filter built from the intersection directly. other is not modified.
+//
+// Returns:
+//   - An *IncompatibleError if the filters cannot be combined.
+func (sbf *StableBloomFilter) Intersect(other *StableBloomFilter) error {
+	if err := sbf.compatible(other); err != nil {
+		return err
+	}
+	for i := range sbf.filter {
+		atomicAnd(&sbf.filter[i], atomic.LoadUint64(&other.filter[i]))
+	}
+	return nil
+}
+
+// EstimateIntersectionSize estimates the number of distinct elements present in both filters.
+//
+// It combines the cardinality estimates of each filter and of their union (the bitwise OR),
+// |A ∩ B| ≈ |A| + |B| - |A ∪ B|. Neither filter is modified. The estimate is unreliable once
+// either filter is close to saturation.
+//
+// Returns:
+//   - The estimated intersection size.
+//   - An *IncompatibleError if the filters cannot be compared.
+func (sbf *StableBloomFilter) EstimateIntersectionSize(other *StableBloomFilter) (float64, error) {
+	if err := sbf.compatible(other); err != nil {
+		return 0, err
+	}
+	countA, countB, countUnion := sbf.occupancy(other)
+	return intersectionSize(countA, countB, countUnion, sbf.m, sbf.k), nil
+}
+
+// EstimateJaccard estimates the Jaccard similarity |A ∩ B| / |A ∪ B| of the elements in both filters.
+//
+// Returns 0 if both filters are empty. Neither filter is modified.
+//
+// Returns:
+//   - The estimated similarity, between 0 and 1.
+//   - An *IncompatibleError if the filters cannot be compared.
+func (sbf *StableBloomFilter) EstimateJaccard(other *StableBloomFilter) (float64, error) {
+	if err := sbf.compatible(other); err != nil {
+		return 0, err
+	}
+	countA, countB, countUnion := sbf.occupancy(other)
+	union := estimateCardinality(countUnion, sbf.m, sbf.k)
+	if union == 0 {
+		return 0, nil
+	}
+	return math.Min(intersectionSize(countA, countB, countUnion, sbf.m, sbf.k)/union, 1), nil
+}
+
+// occupancy counts the bits set in sbf, in other, and in their union.
+func (sbf *StableBloomFilter) occupancy(other *StableBloomFilter) (countA, countB, countUnion uint64) {
+	for i := range sbf.filter {
+		a := atomic.LoadUint64(&sbf.filter[i])
+		b := atomic.LoadUint64(&other.filter[i])
+		countA += uint64(bits.OnesCount64(a))
+		countB += uint64(bits.OnesCount64(b))
+		countUnion += uint64(bits.OnesCount64(a | b))
+	}
+	return countA, countB, countUnion
+}
+
+// intersectionSize estimates |A ∩ B| from the bit counts of A, B and A ∪ B.
+func intersectionSize(countA, countB, countUnion uint64, m, k uint32) float64 {
+	n := estimateCardinality(countA, m, k) + estimateCardinality(countB, m, k) - estimateCardinality(countUnion, m, k)
+	return math.Max(n, 0)
+}
+
+// compatible returns an *IncompatibleError if other does not map elements to the same bits as sbf.
+func (sbf *StableBloomFilter) compatible(other *StableBloomFilter) error {
+	if sbf.m != other.m {
+		return &IncompatibleError{Param: "m", This: fmt.Sprint(sbf.m), Other: fmt.Sprint(other.m)}
+	}
+	if sbf.k != other.k {
+		return &IncompatibleError{Param: "k", This: fmt.Sprint(sbf.k), Other: fmt.Sprint(other.k)}
+	}
+	if sbf.layout != other.layout {
+		return &IncompatibleError{Param: "layout", This: sbf.layout.String(), Other: other.layout.String()}
+	}
+	if !sameHashFuncs(sbf, other) {
+		return &IncompatibleError{Param: "hash", This: describeHash(sbf), Other: describeHash(other)}
+	}
+	return nil
+}
+
+// sameHashFuncs reports whether two filters with the same k hash elements identically.
+func sameHashFuncs(a, b *StableBloomFilter) bool {
+	if a.hashAlg != HashCustom || b.hashAlg != HashCustom {
+		if a.hashAlg != b.hashAlg || len(a.seeds) != len(b.seeds) {
+			return false
+		}
+		for i := range a.seeds {
+			if a.seeds[i] != b.seeds[i] {
+				return false
+			}
+		}
+		return true
+	}
+	// Custom hash functions cannot be compared, but a shared slice is known to match
+	return len(a.hashFuncs) > 0 && &a.hashFuncs[0] == &b.hashFuncs[0]
+}
+
+// describeHash returns a short description of a filter's hash functions for error messages.
+func describeHash(sbf *StableBloomFilter) string {
+	if sbf.hashAlg == HashCustom {
+		return "custom"
+	}
+	if f, ok := lookupHashFamily(sbf.hashAlg); ok && f.Keyed {
+		return fmt.Sprintf("%s (keyed)", sbf.hashAlg)
+	}
+	return fmt.Sprintf("%s seeds %v", sbf.hashAlg, sbf.seeds)
+}
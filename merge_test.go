@@ -0,0 +1,266 @@
+package sbf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMerge(t *testing.T) {
+	a, err := NewDefaultStableBloomFilter(1000, 0.01, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer a.StopDecay()
+	b, err := NewDefaultStableBloomFilter(1000, 0.01, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer b.StopDecay()
+
+	for i := 0; i < 100; i++ {
+		a.Add([]byte(fmt.Sprintf("a%d", i)))
+		b.Add([]byte(fmt.Sprintf("b%d", i)))
+	}
+
+	if err := a.Merge(b); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if !a.Check([]byte(fmt.Sprintf("a%d", i))) || !a.Check([]byte(fmt.Sprintf("b%d", i))) {
+			t.Fatalf("Element %d missing after merge", i)
+		}
+	}
+	if b.Check([]byte("a0")) && b.Check([]byte("a1")) && b.Check([]byte("a2")) {
+		t.Error("Merge modified the other filter")
+	}
+}
+
+func TestMergeConcurrentWithAdd(t *testing.T) {
+	// Decay runs often but clears nothing, so every element must survive
+	a, err := NewStableBloomFilter(1<<16, nil, 0.0, time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer a.StopDecay()
+	b, err := NewStableBloomFilter(1<<16, nil, 0.0, time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer b.StopDecay()
+
+	const perFilter = 2000
+	var wg sync.WaitGroup
+	for _, f := range []struct {
+		sbf    *StableBloomFilter
+		prefix string
+	}{{a, "a"}, {b, "b"}} {
+		wg.Add(1)
+		go func(sbf *StableBloomFilter, prefix string) {
+			defer wg.Done()
+			for i := 0; i < perFilter; i++ {
+				sbf.Add([]byte(fmt.Sprintf("%s_%d", prefix, i)))
+			}
+		}(f.sbf, f.prefix)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for merging := true; merging; {
+		select {
+		case <-done:
+			merging = false
+		default:
+		}
+		if err := a.Merge(b); err != nil {
+			t.Fatalf("Merge failed: %v", err)
+		}
+	}
+
+	for _, prefix := range []string{"a", "b"} {
+		for i := 0; i < perFilter; i++ {
+			if !a.Check([]byte(fmt.Sprintf("%s_%d", prefix, i))) {
+				t.Fatalf("Element %s_%d missing after concurrent Merge", prefix, i)
+			}
+		}
+	}
+}
+
+func TestUnion(t *testing.T) {
+	a, err := NewStableBloomFilter(4096, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer a.StopDecay()
+	b, err := NewStableBloomFilter(4096, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer b.StopDecay()
+
+	a.Add([]byte("only_in_a"))
+	b.Add([]byte("only_in_b"))
+
+	u, err := Union(a, b)
+	if err != nil {
+		t.Fatalf("Union failed: %v", err)
+	}
+	defer u.StopDecay()
+
+	if !u.Check([]byte("only_in_a")) || !u.Check([]byte("only_in_b")) {
+		t.Error("Union is missing elements")
+	}
+	if a.Check([]byte("only_in_b")) {
+		t.Error("Union modified its inputs")
+	}
+}
+
+func TestMergeIncompatible(t *testing.T) {
+	custom := []Hash64{makeHashFunc(1), makeHashFunc(2)}
+	otherCustom := []Hash64{makeHashFunc(1), makeHashFunc(2)}
+	customDefaults, _ := defaultHashFuncs(7) // Same functions as the defaults, but not known to be
+
+	tests := []struct {
+		name      string
+		m1, m2    uint32
+		h1, h2    []Hash64
+		wantParam string
+	}{
+		{name: "size", m1: 1024, m2: 2048, wantParam: "m"},
+		{name: "hash count", m1: 1024, m2: 1024, h2: custom, wantParam: "k"},
+		{name: "default and custom", m1: 1024, m2: 1024, h1: customDefaults, wantParam: "hash"},
+		{name: "different custom slices", m1: 1024, m2: 1024, h1: custom, h2: otherCustom, wantParam: "hash"},
+		{name: "same custom slice", m1: 1024, m2: 1024, h1: custom, h2: custom},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewStableBloomFilter(tt.m1, tt.h1, 0.0, time.Hour)
+			if err != nil {
+				t.Fatalf("Failed to create StableBloomFilter: %v", err)
+			}
+			defer a.StopDecay()
+			b, err := NewStableBloomFilter(tt.m2, tt.h2, 0.0, time.Hour)
+			if err != nil {
+				t.Fatalf("Failed to create StableBloomFilter: %v", err)
+			}
+			defer b.StopDecay()
+
+			err = a.Merge(b)
+			if tt.wantParam == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+
+			var incompatible *IncompatibleError
+			if !errors.As(err, &incompatible) {
+				t.Fatalf("Expected an IncompatibleError, got %v", err)
+			}
+			if incompatible.Param != tt.wantParam {
+				t.Errorf("Expected mismatch on %s, got %s", tt.wantParam, incompatible.Param)
+			}
+			if !errors.Is(err, ErrIncompatible) {
+				t.Error("Expected errors.Is(err, ErrIncompatible)")
+			}
+			if _, err := Union(a, b); err == nil {
+				t.Error("Expected Union to fail as well")
+			}
+		})
+	}
+}
+
+func TestIntersect(t *testing.T) {
+	a, err := NewDefaultStableBloomFilter(1000, 0.01, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer a.StopDecay()
+	b, err := NewDefaultStableBloomFilter(1000, 0.01, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer b.StopDecay()
+
+	for i := 0; i < 100; i++ {
+		a.Add([]byte(fmt.Sprintf("shared%d", i)))
+		b.Add([]byte(fmt.Sprintf("shared%d", i)))
+		a.Add([]byte(fmt.Sprintf("a%d", i)))
+		b.Add([]byte(fmt.Sprintf("b%d", i)))
+	}
+
+	if err := a.Intersect(b); err != nil {
+		t.Fatalf("Intersect failed: %v", err)
+	}
+
+	onlyA := 0
+	for i := 0; i < 100; i++ {
+		if !a.Check([]byte(fmt.Sprintf("shared%d", i))) {
+			t.Fatalf("Shared element %d missing after intersect", i)
+		}
+		if a.Check([]byte(fmt.Sprintf("a%d", i))) {
+			onlyA++
+		}
+	}
+	if onlyA > 10 {
+		t.Errorf("Expected few elements only in a to survive, got %d", onlyA)
+	}
+}
+
+func TestEstimateIntersectionAndJaccard(t *testing.T) {
+	a, err := NewDefaultStableBloomFilter(10000, 0.01, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer a.StopDecay()
+	b, err := NewDefaultStableBloomFilter(10000, 0.01, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer b.StopDecay()
+
+	if j, err := a.EstimateJaccard(b); err != nil || j != 0 {
+		t.Errorf("Expected 0 similarity for empty filters, got %f (%v)", j, err)
+	}
+
+	// 2000 shared, 1000 only in a, 1000 only in b: |A ∩ B| = 2000, |A ∪ B| = 4000
+	for i := 0; i < 2000; i++ {
+		a.Add([]byte(fmt.Sprintf("shared%d", i)))
+		b.Add([]byte(fmt.Sprintf("shared%d", i)))
+	}
+	for i := 0; i < 1000; i++ {
+		a.Add([]byte(fmt.Sprintf("a%d", i)))
+		b.Add([]byte(fmt.Sprintf("b%d", i)))
+	}
+
+	inter, err := a.EstimateIntersectionSize(b)
+	if err != nil {
+		t.Fatalf("EstimateIntersectionSize failed: %v", err)
+	}
+	if math.Abs(inter-2000) > 150 {
+		t.Errorf("Expected intersection close to 2000, got %f", inter)
+	}
+
+	j, err := a.EstimateJaccard(b)
+	if err != nil {
+		t.Fatalf("EstimateJaccard failed: %v", err)
+	}
+	if math.Abs(j-0.5) > 0.05 {
+		t.Errorf("Expected Jaccard close to 0.5, got %f", j)
+	}
+
+	other, err := NewStableBloomFilter(1024, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer other.StopDecay()
+	if _, err := a.EstimateJaccard(other); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("Expected ErrIncompatible, got %v", err)
+	}
+}
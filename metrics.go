@@ -0,0 +1,61 @@
+package sbf
+
+// MetricKind describes how a Metric's value evolves over time.
+type MetricKind int
+
+const (
+	// GaugeMetric is a value that can go up and down.
+	GaugeMetric MetricKind = iota
+	// CounterMetric is a cumulative value that only increases.
+	CounterMetric
+)
+
+// Metric is a single named statistic derived from Stats.
+//
+// Exporters use Name and Help as-is, so a filter reports the same metric names whichever
+// monitoring system it is exported to.
+type Metric struct {
+	Name  string
+	Help  string
+	Kind  MetricKind
+	Value float64
+}
+
+// Metric names shared by all exporters.
+const (
+	MetricFillRatio         = "sbf_fill_ratio"
+	MetricEstimatedFPR      = "sbf_estimated_false_positive_rate"
+	MetricEstimatedItems    = "sbf_estimated_items"
+	MetricBitsSet           = "sbf_bits_set"
+	MetricSizeBits          = "sbf_size_bits"
+	MetricSizeBytes         = "sbf_size_bytes"
+	MetricAdds              = "sbf_adds_total"
+	MetricChecks            = "sbf_checks_total"
+	MetricDecayRuns         = "sbf_decay_runs_total"
+	MetricDecayCleared      = "sbf_decay_cleared_bits_total"
+	MetricLastDecayDuration = "sbf_last_decay_duration_seconds"
+	MetricLastDecayCleared  = "sbf_last_decay_cleared_bits"
+	MetricDecayDuration     = "sbf_decay_duration_seconds"  // Distribution of decay pass durations
+	MetricDecayClearedBits  = "sbf_decay_pass_cleared_bits" // Distribution of bits cleared per decay pass
+)
+
+// Metrics returns the statistics in s as named metrics.
+//
+// The set and order of metrics does not depend on the values, so Stats{}.Metrics() can be used
+// to enumerate the metric names and descriptions up front.
+func (s Stats) Metrics() []Metric {
+	return []Metric{
+		{MetricFillRatio, "Fraction of bits currently set in the filter.", GaugeMetric, s.FillRatio},
+		{MetricEstimatedFPR, "Estimated false positive rate of the filter.", GaugeMetric, s.EstimatedFPR},
+		{MetricEstimatedItems, "Estimated number of distinct items in the filter.", GaugeMetric, s.EstimatedCardinality},
+		{MetricBitsSet, "Number of bits currently set in the filter.", GaugeMetric, float64(s.BitsSet)},
+		{MetricSizeBits, "Size of the filter in bits.", GaugeMetric, float64(s.M)},
+		{MetricSizeBytes, "Memory used by the filter's bit array in bytes.", GaugeMetric, float64(s.Bytes)},
+		{MetricAdds, "Total number of Add calls.", CounterMetric, float64(s.Adds)},
+		{MetricChecks, "Total number of Check calls.", CounterMetric, float64(s.Checks)},
+		{MetricDecayRuns, "Total number of completed decay passes.", CounterMetric, float64(s.DecayRuns)},
+		{MetricDecayCleared, "Total number of bits cleared by decay.", CounterMetric, float64(s.DecayCleared)},
+		{MetricLastDecayDuration, "Duration of the most recent decay pass in seconds.", GaugeMetric, s.LastDecayDuration.Seconds()},
+		{MetricLastDecayCleared, "Number of bits cleared by the most recent decay pass.", GaugeMetric, float64(s.LastDecayCleared)},
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !race
+
+package sbf
+
+// raceEnabled reports whether the tests run under the race detector.
+const raceEnabled = false
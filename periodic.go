@@ -0,0 +1,45 @@
+package sbf
+
+import (
+	"sync"
+	"time"
+)
+
+// periodic runs a function on its own goroutine at a fixed interval, such as the decay process of
+// StableBloomFilter. The zero value is idle; stop is safe to call on it.
+type periodic struct {
+	ticker   *time.Ticker
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+	once     sync.Once
+}
+
+// start runs fn every interval until stop is called.
+func (p *periodic) start(interval time.Duration, fn func()) {
+	p.ticker = time.NewTicker(interval)
+	p.stopChan = make(chan struct{})
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		for {
+			select {
+			case <-p.ticker.C:
+				fn()
+			case <-p.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// stop stops the goroutine and waits for a running fn to return. It is safe to call more than once.
+func (p *periodic) stop() {
+	p.once.Do(func() {
+		if p.ticker == nil {
+			return
+		}
+		p.ticker.Stop()
+		close(p.stopChan)
+		p.wg.Wait()
+	})
+}
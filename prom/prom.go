@@ -0,0 +1,130 @@
+// Package prom exposes Stable Bloom Filter health metrics to Prometheus.
+//
+// A Collector tracks any number of named filters and reports their fill ratio, estimated
+// false positive rate, Add/Check counters and decay activity, labeled by filter name.
+package prom
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/Alfex4936/sbf-go"
+)
+
+// Collector is a prometheus.Collector reporting metrics for a set of named Stable Bloom Filters.
+//
+// Gauges and counters are read from the filters' Stats at scrape time. Decay duration and
+// bits cleared per decay are recorded as histograms as each decay pass completes.
+type Collector struct {
+	mu      sync.Mutex
+	filters map[string]*entry
+
+	descs []*prometheus.Desc // Indexed like sbf.Stats.Metrics
+
+	decayDuration *prometheus.HistogramVec
+	decayCleared  *prometheus.HistogramVec
+}
+
+// entry is a filter tracked by the collector.
+type entry struct {
+	filter *sbf.StableBloomFilter
+	cancel func() // Unregisters the decay callback
+}
+
+// NewCollector creates a Collector with no filters registered.
+//
+// Metric names are the ones defined by the sbf package, such as sbf_fill_ratio and sbf_adds_total.
+func NewCollector() *Collector {
+	labels := []string{"filter"}
+
+	metrics := sbf.Stats{}.Metrics()
+	descs := make([]*prometheus.Desc, len(metrics))
+	for i, m := range metrics {
+		descs[i] = prometheus.NewDesc(m.Name, m.Help, labels, nil)
+	}
+
+	return &Collector{
+		filters: make(map[string]*entry),
+		descs:   descs,
+
+		decayDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    sbf.MetricDecayDuration,
+			Help:    "Duration of decay passes.",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}, labels),
+		decayCleared: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    sbf.MetricDecayClearedBits,
+			Help:    "Number of bits cleared per decay pass.",
+			Buckets: prometheus.ExponentialBuckets(1, 4, 14),
+		}, labels),
+	}
+}
+
+// Register starts reporting metrics for f under the given filter name.
+//
+// Returns an error if a filter with the same name is already registered.
+func (c *Collector) Register(name string, f *sbf.StableBloomFilter) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.filters[name]; ok {
+		return fmt.Errorf("filter %q is already registered", name)
+	}
+
+	duration := c.decayDuration.WithLabelValues(name)
+	cleared := c.decayCleared.WithLabelValues(name)
+	cancel := f.OnDecay(func(e sbf.DecayEvent) {
+		duration.Observe(e.Duration.Seconds())
+		cleared.Observe(float64(e.Cleared))
+	})
+
+	c.filters[name] = &entry{filter: f, cancel: cancel}
+	return nil
+}
+
+// Unregister stops reporting metrics for the named filter.
+//
+// Returns false if no filter with that name is registered.
+func (c *Collector) Unregister(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.filters[name]
+	if !ok {
+		return false
+	}
+	e.cancel()
+	delete(c.filters, name)
+	c.decayDuration.DeleteLabelValues(name)
+	c.decayCleared.DeleteLabelValues(name)
+	return true
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range c.descs {
+		ch <- desc
+	}
+	c.decayDuration.Describe(ch)
+	c.decayCleared.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name, e := range c.filters {
+		for i, m := range e.filter.Stats().Metrics() {
+			valueType := prometheus.GaugeValue
+			if m.Kind == sbf.CounterMetric {
+				valueType = prometheus.CounterValue
+			}
+			ch <- prometheus.MustNewConstMetric(c.descs[i], valueType, m.Value, name)
+		}
+	}
+	c.decayDuration.Collect(ch)
+	c.decayCleared.Collect(ch)
+}
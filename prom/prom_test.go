@@ -0,0 +1,96 @@
+package prom
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/Alfex4936/sbf-go"
+)
+
+func TestCollectorReportsFilters(t *testing.T) {
+	f, err := sbf.NewStableBloomFilter(1024, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer f.StopDecay()
+
+	for i := 0; i < 10; i++ {
+		f.Add([]byte(fmt.Sprintf("element%d", i)))
+	}
+	f.Check([]byte("element0"))
+
+	c := NewCollector()
+	if err := c.Register("dedup", f); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := c.Register("dedup", f); err == nil {
+		t.Error("Expected an error registering a duplicate name")
+	}
+
+	expected := `
+# HELP sbf_adds_total Total number of Add calls.
+# TYPE sbf_adds_total counter
+sbf_adds_total{filter="dedup"} 10
+# HELP sbf_checks_total Total number of Check calls.
+# TYPE sbf_checks_total counter
+sbf_checks_total{filter="dedup"} 1
+# HELP sbf_size_bits Size of the filter in bits.
+# TYPE sbf_size_bits gauge
+sbf_size_bits{filter="dedup"} 1024
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"sbf_adds_total", "sbf_checks_total", "sbf_size_bits"); err != nil {
+		t.Error(err)
+	}
+
+	if n := testutil.CollectAndCount(c, "sbf_fill_ratio", "sbf_estimated_false_positive_rate", "sbf_estimated_items"); n != 3 {
+		t.Errorf("Expected 3 estimate metrics, got %d", n)
+	}
+
+	if !c.Unregister("dedup") {
+		t.Error("Expected Unregister to find the filter")
+	}
+	if n := testutil.CollectAndCount(c); n != 0 {
+		t.Errorf("Expected no metrics after Unregister, got %d", n)
+	}
+}
+
+func TestCollectorRecordsDecay(t *testing.T) {
+	f, err := sbf.NewStableBloomFilter(1024, nil, 1.0, time.Millisecond*5)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer f.StopDecay()
+
+	c := NewCollector()
+	if err := c.Register("decaying", f); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+
+	deadline := time.Now().Add(time.Second)
+	for f.Stats().DecayRuns == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	for _, mf := range families {
+		if mf.GetName() == "sbf_decay_duration_seconds" {
+			if count := mf.GetMetric()[0].GetHistogram().GetSampleCount(); count == 0 {
+				t.Error("Expected decay duration observations")
+			}
+			return
+		}
+	}
+	t.Error("sbf_decay_duration_seconds was not reported")
+}
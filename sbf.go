@@ -21,15 +21,39 @@ type Hash64 func(data []byte) uint64
 // It allows approximate membership queries with support for element decay over time.
 // The filter supports concurrent access and can be safely used by multiple goroutines.
 type StableBloomFilter struct {
-	m           uint32       // Size of the filter (number of bits)
-	k           uint32       // Number of hash functions
-	decayRate   float64      // Probability of decaying bits
-	filter      []uint64     // Bit array represented as slice of uint64 for efficiency
-	numBuckets  uint32       // Number of buckets (filter size divided by 64)
-	decayTicker *time.Ticker // Ticker for decay process
-	hashFuncs   []Hash64     // Slice of hash functions
-	stopChan    chan struct{}
-	wg          sync.WaitGroup
+	m           uint32        // Size of the filter (number of bits)
+	k           uint32        // Number of hash functions
+	decayRate   float64       // Probability of decaying bits
+	filter      []uint64      // Bit array represented as slice of uint64 for efficiency
+	numBuckets  uint32        // Number of buckets (filter size divided by 64)
+	hashFuncs   []Hash64      // Slice of hash functions
+	sum128      sum128Func    // 128-bit hash the hash functions derive from, nil unless double hashing
+	hashAlg     HashAlgorithm // Algorithm the hash functions were built from, HashCustom for custom ones
+	seeds       []uint64      // Seeds of the hash algorithm, nil for custom hash functions
+	layout      Layout        // How hash functions map to bits
+	partSize    uint32        // Bits per partition with LayoutPartitioned, m otherwise
+	decayPeriod time.Duration // Time duration between decay operations
+	decayer     periodic      // Background decay process
+
+	adds             shardedCounter // Cumulative number of Add calls
+	checks           shardedCounter // Cumulative number of Check calls
+	decayRuns        atomic.Uint64  // Number of completed decay passes
+	lastDecayNanos   atomic.Int64   // Duration of the most recent decay pass
+	lastDecayCleared atomic.Uint64  // Bits cleared by the most recent decay pass
+	decayCleared     atomic.Uint64  // Cumulative number of bits cleared by decay
+
+	listenersMu  sync.Mutex
+	listeners    map[uint64]func(DecayEvent) // Callbacks run after each decay pass
+	thresholds   map[uint64]*threshold       // Threshold hooks evaluated after each decay pass
+	nextListener uint64
+
+	evalMu         sync.Mutex    // Serializes threshold evaluations
+	thresholdEvery atomic.Uint64 // Also evaluate thresholds every N Adds (0 disables)
+	addsSinceEval  atomic.Uint64
+
+	tracking atomic.Bool   // Whether word changes are recorded for Delta
+	versions []uint64      // Version at which each word last gained bits
+	version  atomic.Uint64 // Current change version
 }
 
 // NewStableBloomFilter creates a new Stable Bloom Filter with the specified parameters.
@@ -47,38 +71,59 @@ type StableBloomFilter struct {
 //   - An error if initialization fails.
 func NewStableBloomFilter(m uint32, hashFuncs []Hash64, decayRate float64, decayInterval time.Duration) (*StableBloomFilter, error) {
 	// If no hash functions are provided, use default hash functions.
+	alg := HashCustom
+	var seeds []uint64
 	if len(hashFuncs) == 0 {
 		defaultK := uint32(7) // Default number of hash functions
-		hashFuncs = make([]Hash64, defaultK)
-		for i := uint32(0); i < defaultK; i++ {
-			hashFuncs[i] = makeHashFunc(uint64(i))
-		}
+		hashFuncs, seeds = defaultHashFuncs(defaultK)
+		alg = HashXXH3Seeded
 	}
+	return newStableBloomFilter(m, hashFuncs, alg, seeds, LayoutStandard, decayRate, decayInterval)
+}
+
+// newStableBloomFilter creates a Stable Bloom Filter, recording the algorithm and seeds its hash functions were built from.
+func newStableBloomFilter(m uint32, hashFuncs []Hash64, alg HashAlgorithm, seeds []uint64, layout Layout, decayRate float64, decayInterval time.Duration) (*StableBloomFilter, error) {
+	sbf := &StableBloomFilter{}
+	sbf.init(m, hashFuncs, alg, seeds, layout, decayRate, decayInterval)
+	return sbf, nil
+}
+
+// init sets up a zero StableBloomFilter and starts its decay process.
+func (sbf *StableBloomFilter) init(m uint32, hashFuncs []Hash64, alg HashAlgorithm, seeds []uint64, layout Layout, decayRate float64, decayInterval time.Duration) {
 	k := uint32(len(hashFuncs))
 
-	// Ensure m is a multiple of 64 for alignment
-	if m%64 != 0 {
-		m += 64 - (m % 64)
+	// Ensure m is a multiple of 64 for alignment, or of 64·k so that partitions are whole words
+	align := uint32(64)
+	if layout == LayoutPartitioned {
+		align *= k
+	}
+	if m%align != 0 {
+		m += align - (m % align)
 	}
 
 	numBuckets := m / 64
-
-	sbf := &StableBloomFilter{
-		m:           m,
-		k:           k,
-		decayRate:   decayRate,
-		filter:      make([]uint64, numBuckets),
-		numBuckets:  numBuckets,
-		hashFuncs:   hashFuncs,
-		decayTicker: time.NewTicker(decayInterval),
-		stopChan:    make(chan struct{}),
+	partSize := m
+	if layout == LayoutPartitioned {
+		partSize = m / k
 	}
 
-	// Start decay process
-	sbf.wg.Add(1)
-	go sbf.startDecay()
+	sbf.m = m
+	sbf.k = k
+	sbf.decayRate = decayRate
+	sbf.filter = make([]uint64, numBuckets)
+	sbf.numBuckets = numBuckets
+	sbf.hashFuncs = hashFuncs
+	sbf.sum128 = buildSum128(alg, seeds)
+	sbf.hashAlg = alg
+	sbf.seeds = seeds
+	sbf.layout = layout
+	sbf.partSize = partSize
+	sbf.decayPeriod = decayInterval
+	sbf.adds = newShardedCounter()
+	sbf.checks = newShardedCounter()
 
-	return sbf, nil
+	// Start decay process
+	sbf.decayer.start(decayInterval, sbf.decay)
 }
 
 // NewDefaultStableBloomFilter creates a new Stable Bloom Filter with optimal settings based on expected items and desired false positive rate.
@@ -106,10 +151,7 @@ func NewDefaultStableBloomFilter(expectedItems uint32, falsePositiveRate float64
 	}
 
 	// Generate default hash functions
-	hashFuncs := make([]Hash64, k)
-	for i := uint32(0); i < k; i++ {
-		hashFuncs[i] = makeHashFunc(uint64(i))
-	}
+	hashFuncs, seeds := defaultHashFuncs(k)
 
 	// Assign default decayRate if zero
 	if decayRate == 0 {
@@ -121,18 +163,26 @@ func NewDefaultStableBloomFilter(expectedItems uint32, falsePositiveRate float64
 		decayInterval = time.Minute
 	}
 
-	return NewStableBloomFilter(m, hashFuncs, decayRate, decayInterval)
+	return newStableBloomFilter(m, hashFuncs, HashXXH3Seeded, seeds, LayoutStandard, decayRate, decayInterval)
 }
 
 // Add inserts an element into the Stable Bloom Filter.
 //
 // The element is represented as a byte slice.
 func (sbf *StableBloomFilter) Add(data []byte) {
+	sbf.adds.inc()
+	h1, h2 := sbf.sum(data)
 	for i := uint32(0); i < sbf.k; i++ {
-		idx := sbf.hashIndex(data, i)
+		idx := sbf.index(data, h1, h2, i)
 		bucketIdx := idx / 64
 		bitIdx := idx % 64
-		atomicSetBit(&sbf.filter[bucketIdx], bitIdx)
+		if atomicSetBit(&sbf.filter[bucketIdx], bitIdx) {
+			sbf.markChanged(bucketIdx)
+		}
+	}
+
+	if n := sbf.thresholdEvery.Load(); n != 0 && sbf.addsSinceEval.Add(1)%n == 0 {
+		sbf.tryEvaluateThresholds()
 	}
 }
 
@@ -140,8 +190,10 @@ func (sbf *StableBloomFilter) Add(data []byte) {
 //
 // Returns true if the element might be in the filter, or false if the element is definitely not in the filter.
 func (sbf *StableBloomFilter) Check(data []byte) bool {
+	sbf.checks.inc()
+	h1, h2 := sbf.sum(data)
 	for i := uint32(0); i < sbf.k; i++ {
-		idx := sbf.hashIndex(data, i)
+		idx := sbf.index(data, h1, h2, i)
 		bucketIdx := idx / 64
 		bitIdx := idx % 64
 		if !atomicGetBit(&sbf.filter[bucketIdx], bitIdx) {
@@ -154,27 +206,68 @@ func (sbf *StableBloomFilter) Check(data []byte) bool {
 // StopDecay stops the decay process of the Stable Bloom Filter.
 //
 // This function should be called when the filter is no longer needed to clean up resources.
+// Calling it more than once has no effect. It waits for a running decay pass and its callbacks to
+// finish, so it must not be called from an OnDecay, OnFillRatioAbove or OnEstimatedFPRAbove
+// callback; start a goroutine to call it from there.
 func (sbf *StableBloomFilter) StopDecay() {
-	sbf.decayTicker.Stop()
-	close(sbf.stopChan)
-	sbf.wg.Wait()
+	sbf.decayer.stop()
 }
 
 // EstimateFalsePositiveRate estimates the current false positive rate of the Stable Bloom Filter.
 //
 // The estimation is based on the fraction of bits set in the filter and the number of hash functions.
+// With LayoutPartitioned it is the product of the fill ratios of the k partitions.
 func (sbf *StableBloomFilter) EstimateFalsePositiveRate() float64 {
-	// Calculate the fraction of bits set
-	var bitsSet uint32
-	for _, bucket := range sbf.filter {
-		bitsSet += uint32(bits.OnesCount64(atomic.LoadUint64(&bucket)))
+	if sbf.layout == LayoutPartitioned {
+		return sbf.partitionedFPR()
 	}
+
+	// Calculate the fraction of bits set
+	bitsSet := sbf.countBitsSet()
 	fractionBitsSet := float64(bitsSet) / float64(sbf.m)
 
 	// Use the standard formula for Bloom filters
 	return math.Pow(fractionBitsSet, float64(sbf.k))
 }
 
+// partitionedFPR returns the product of the fill ratios of the partitions.
+func (sbf *StableBloomFilter) partitionedFPR() float64 {
+	wordsPerPart := sbf.partSize / 64
+	fpr := 1.0
+	for p := uint32(0); p < sbf.k; p++ {
+		var bitsSet uint64
+		for i := p * wordsPerPart; i < (p+1)*wordsPerPart; i++ {
+			bitsSet += uint64(bits.OnesCount64(atomic.LoadUint64(&sbf.filter[i])))
+		}
+		fpr *= float64(bitsSet) / float64(sbf.partSize)
+	}
+	return fpr
+}
+
+// EstimateCount estimates the number of distinct elements currently in the Stable Bloom Filter.
+//
+// It uses the Swamidass–Baldi estimator -m/k * ln(1 - X/m), where X is the number of bits set.
+// Elements that have fully decayed are not counted. Returns +Inf if every bit is set.
+func (sbf *StableBloomFilter) EstimateCount() float64 {
+	return estimateCardinality(sbf.countBitsSet(), sbf.m, sbf.k)
+}
+
+// EstimateCountBounds estimates the number of distinct elements together with a confidence interval.
+//
+// Parameters:
+//   - confidence: Probability that the interval contains the true count (between 0 and 1, e.g. 0.95).
+//
+// Returns:
+//   - The lower bound, the estimate and the upper bound. The upper bound is +Inf if every bit is set.
+//   - An error if the confidence is invalid.
+func (sbf *StableBloomFilter) EstimateCountBounds(confidence float64) (lower, estimate, upper float64, err error) {
+	if confidence <= 0.0 || confidence >= 1.0 {
+		return 0, 0, 0, errors.New("confidence must be between 0 and 1 (exclusive)")
+	}
+	lower, estimate, upper = cardinalityBounds(sbf.countBitsSet(), sbf.m, sbf.k, confidence)
+	return lower, estimate, upper, nil
+}
+
 // OptimalM calculates the optimal filter size (number of bits) for a given number of expected items and desired false positive rate.
 //
 // Parameters:
@@ -217,36 +310,57 @@ func OptimalK(m uint32, n uint32) (uint32, error) {
 
 // hashIndex computes the hash index for the i-th hash function.
 func (sbf *StableBloomFilter) hashIndex(data []byte, i uint32) uint32 {
-	sum := sbf.hashFuncs[i](data)
-	return uint32(sum % uint64(sbf.m))
+	h1, h2 := sbf.sum(data)
+	return sbf.index(data, h1, h2, i)
 }
 
-// startDecay periodically decays the filter.
-func (sbf *StableBloomFilter) startDecay() {
-	defer sbf.wg.Done()
-	for {
-		select {
-		case <-sbf.decayTicker.C:
-			sbf.decay()
-		case <-sbf.stopChan:
-			return
-		}
+// sum returns the 128-bit hash of an element for double-hashing algorithms, or zeros otherwise.
+func (sbf *StableBloomFilter) sum(data []byte) (h1, h2 uint64) {
+	if sbf.sum128 == nil {
+		return 0, 0
 	}
+	return sbf.sum128(data)
+}
+
+// index computes the hash index for the i-th hash function.
+//
+// For double-hashing algorithms the index is derived from the element's 128-bit hash h1, h2, so
+// the element is hashed once for all k functions; otherwise the i-th hash function is called.
+// With LayoutPartitioned the index falls in partition i.
+func (sbf *StableBloomFilter) index(data []byte, h1, h2 uint64, i uint32) uint32 {
+	var sum uint64
+	if sbf.sum128 != nil {
+		sum = h1 + uint64(i)*h2
+	} else {
+		sum = sbf.hashFuncs[i](data)
+	}
+	if sbf.layout == LayoutPartitioned {
+		return i*sbf.partSize + uint32(sum%uint64(sbf.partSize))
+	}
+	return uint32(sum % uint64(sbf.m))
 }
 
 // decay unsets bits randomly based on decayRate.
+//
+// The bit array is split into one chunk per CPU, or with LayoutPartitioned into one chunk per
+// partition, and the chunks are decayed in parallel.
 func (sbf *StableBloomFilter) decay() {
-	numCPU := runtime.NumCPU()
+	started := time.Now()
+	numChunks := runtime.NumCPU()
+	if sbf.layout == LayoutPartitioned {
+		numChunks = int(sbf.k)
+	}
 	var wg sync.WaitGroup
-	chunkSize := int(sbf.numBuckets) / numCPU
+	var cleared atomic.Uint64
+	chunkSize := int(sbf.numBuckets) / numChunks
 	if chunkSize == 0 {
 		chunkSize = int(sbf.numBuckets)
 	}
 
-	for i := 0; i < numCPU; i++ {
+	for i := 0; i < numChunks; i++ {
 		start := i * chunkSize
 		end := start + chunkSize
-		if i == numCPU-1 || end > int(sbf.numBuckets) {
+		if i == numChunks-1 || end > int(sbf.numBuckets) {
 			end = int(sbf.numBuckets)
 		}
 
@@ -255,20 +369,65 @@ func (sbf *StableBloomFilter) decay() {
 			defer wg.Done()
 			randSrc := rand.New(rand.NewSource(time.Now().UnixNano() + int64(start)))
 			decayRate := sbf.decayRate
+			var n uint64
 			for j := start; j < end; j++ {
 				oldVal := atomic.LoadUint64(&sbf.filter[j])
-				newVal := decayBucket(oldVal, decayRate, randSrc)
-				atomic.StoreUint64(&sbf.filter[j], newVal)
+				decayed := oldVal &^ decayBucket(oldVal, decayRate, randSrc)
+				if decayed == 0 {
+					continue
+				}
+				// Clear only the decayed bits, keeping bits set concurrently by Add or Merge
+				atomicAnd(&sbf.filter[j], ^decayed)
+				n += uint64(bits.OnesCount64(decayed))
 			}
+			cleared.Add(n)
 		}(start, end)
 	}
 	wg.Wait()
+
+	event := DecayEvent{
+		Duration: time.Since(started),
+		Cleared:  cleared.Load(),
+	}
+	sbf.lastDecayCleared.Store(event.Cleared)
+	sbf.lastDecayNanos.Store(int64(event.Duration))
+	sbf.decayCleared.Add(event.Cleared)
+	sbf.decayRuns.Add(1)
+
+	sbf.notifyDecay(event)
+	sbf.evaluateThresholds()
 }
 
 // atomicSetBit sets a bit atomically.
-func atomicSetBit(addr *uint64, n uint32) {
-	mask := uint64(1) << n
-	*addr |= mask // go1.23 >= OrUint64
+//
+// Returns true if the bit was newly set.
+func atomicSetBit(addr *uint64, n uint32) bool {
+	return atomicOr(addr, uint64(1)<<n)
+}
+
+// atomicOr sets the bits of mask in *addr atomically.
+//
+// Returns true if any bit was newly set.
+func atomicOr(addr *uint64, mask uint64) bool {
+	for {
+		old := atomic.LoadUint64(addr)
+		if old|mask == old {
+			return false
+		}
+		if atomic.CompareAndSwapUint64(addr, old, old|mask) {
+			return true
+		}
+	}
+}
+
+// atomicAnd clears the bits of *addr that are not set in mask atomically.
+func atomicAnd(addr *uint64, mask uint64) {
+	for {
+		old := atomic.LoadUint64(addr)
+		if old&mask == old || atomic.CompareAndSwapUint64(addr, old, old&mask) {
+			return
+		}
+	}
 }
 
 // atomicGetBit gets a bit atomically.
@@ -278,21 +437,36 @@ func atomicGetBit(addr *uint64, n uint32) bool {
 }
 
 // decayBucket decays bits in a bucket based on the decay rate.
+//
+// Each set bit is cleared independently with probability decayRate. The loop walks a copy of the
+// bucket, so clearing a bit does not skip or clear the bits after it.
 func decayBucket(bucket uint64, decayRate float64, randSrc *rand.Rand) uint64 {
 	if bucket == 0 {
 		return 0
 	}
 
-	for bucket != 0 {
-		bitPos := bits.TrailingZeros64(bucket)
+	remaining := bucket
+	for remaining != 0 {
+		bitPos := bits.TrailingZeros64(remaining)
 		if randSrc.Float64() < decayRate {
 			bucket &^= 1 << bitPos
 		}
-		bucket &= bucket - 1 // Clear the least significant bit set
+		remaining &= remaining - 1 // Clear the least significant bit set
 	}
 	return bucket
 }
 
+// defaultHashFuncs returns k xxh3 hash functions seeded 0..k-1 along with their seeds.
+func defaultHashFuncs(k uint32) ([]Hash64, []uint64) {
+	hashFuncs := make([]Hash64, k)
+	seeds := make([]uint64, k)
+	for i := uint32(0); i < k; i++ {
+		seeds[i] = uint64(i)
+		hashFuncs[i] = makeHashFunc(seeds[i])
+	}
+	return hashFuncs, seeds
+}
+
 // makeHashFunc returns a Hash64 function using xxh3 with a given seed.
 func makeHashFunc(seed uint64) Hash64 {
 	return func(data []byte) uint64 {
@@ -135,3 +135,40 @@ func BenchmarkCheckConcurrent(b *testing.B) {
 	}
 	wg.Wait()
 }
+
+func BenchmarkHashAlgorithms(b *testing.B) {
+	algorithms := []HashAlgorithm{HashXXH3Seeded, HashXXH3Double, HashFNV1a, HashMurmur3, HashSipHash}
+
+	for _, alg := range algorithms {
+		b.Run(alg.String(), func(b *testing.B) {
+			sbf, err := NewStableBloomFilterWithHash(1<<20, 7, alg, nil, 0.0, time.Hour)
+			if err != nil {
+				b.Fatalf("Failed to create StableBloomFilter: %v", err)
+			}
+			defer sbf.StopDecay()
+
+			data := []byte("benchmark_data")
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				sbf.Add(data)
+				sbf.Check(data)
+			}
+		})
+	}
+}
+
+func BenchmarkTypedAdd(b *testing.B) {
+	sbf, err := NewStableBloomFilter(1<<20, nil, 0.01, time.Minute)
+	if err != nil {
+		b.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	typed := NewTyped(sbf, IntegerEncoder[int64])
+	defer typed.StopDecay()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		typed.Add(int64(i))
+	}
+}
@@ -81,8 +81,8 @@ func TestDecayFunction(t *testing.T) {
 	time.Sleep(time.Millisecond * 20)
 
 	// Check that all bits have been decayed
-	for _, bucket := range sbf.filter {
-		val := atomic.LoadUint64(&bucket)
+	for i := range sbf.filter {
+		val := atomic.LoadUint64(&sbf.filter[i])
 		if val != 0 {
 			t.Error("Decay did not clear all bits as expected")
 			break
@@ -130,6 +130,21 @@ func TestStartDecay(t *testing.T) {
 	}
 }
 
+func TestStopDecayTwice(t *testing.T) {
+	sbf, err := NewStableBloomFilter(1024, nil, 0.5, time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	sbf.StopDecay()
+	sbf.StopDecay()
+
+	runs := sbf.Stats().DecayRuns
+	time.Sleep(5 * time.Millisecond)
+	if got := sbf.Stats().DecayRuns; got != runs {
+		t.Errorf("Expected no decay after StopDecay, got %d more runs", got-runs)
+	}
+}
+
 func TestConcurrency(t *testing.T) {
 	// Initialize a StableBloomFilter instance
 	sbf, err := NewStableBloomFilter(1024, nil, 0.0, time.Hour)
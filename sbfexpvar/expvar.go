@@ -0,0 +1,57 @@
+// Package sbfexpvar publishes Stable Bloom Filter statistics through the standard expvar package.
+//
+// The published JSON object uses the metric names defined by the sbf package, so the same
+// names appear here as in the Prometheus and OpenTelemetry exporters.
+package sbfexpvar
+
+import (
+	"expvar"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Alfex4936/sbf-go"
+)
+
+// Var is an expvar.Var reporting the statistics of a Stable Bloom Filter as a JSON object.
+//
+// Statistics are read from the filter each time the variable is rendered.
+type Var struct {
+	filter *sbf.StableBloomFilter
+}
+
+// New creates a Var for the given filter without publishing it.
+func New(f *sbf.StableBloomFilter) *Var {
+	return &Var{filter: f}
+}
+
+// Publish creates a Var for the given filter and publishes it under name.
+//
+// Like expvar.Publish, it panics if name is already registered.
+func Publish(name string, f *sbf.StableBloomFilter) *Var {
+	v := New(f)
+	expvar.Publish(name, v)
+	return v
+}
+
+// String implements expvar.Var.
+//
+// Values that are not finite, such as the estimated item count of a saturated filter, are rendered as null.
+func (v *Var) String() string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, m := range v.filter.Stats().Metrics() {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(strconv.Quote(m.Name))
+		b.WriteString(": ")
+		if math.IsInf(m.Value, 0) || math.IsNaN(m.Value) {
+			b.WriteString("null")
+		} else {
+			b.WriteString(strconv.FormatFloat(m.Value, 'g', -1, 64))
+		}
+	}
+	b.WriteByte('}')
+	return b.String()
+}
@@ -0,0 +1,76 @@
+package sbfexpvar
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Alfex4936/sbf-go"
+)
+
+func TestVarString(t *testing.T) {
+	f, err := sbf.NewStableBloomFilter(1024, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer f.StopDecay()
+
+	f.Add([]byte("element"))
+
+	var got map[string]*float64
+	if err := json.Unmarshal([]byte(New(f).String()), &got); err != nil {
+		t.Fatalf("String did not produce valid JSON: %v", err)
+	}
+
+	for _, m := range f.Stats().Metrics() {
+		if _, ok := got[m.Name]; !ok {
+			t.Errorf("Metric %s missing from JSON", m.Name)
+		}
+	}
+	if v := got[sbf.MetricAdds]; v == nil || *v != 1 {
+		t.Errorf("Expected %s to be 1, got %v", sbf.MetricAdds, v)
+	}
+	if v := got[sbf.MetricSizeBits]; v == nil || *v != 1024 {
+		t.Errorf("Expected %s to be 1024, got %v", sbf.MetricSizeBits, v)
+	}
+}
+
+func TestVarSaturatedFilter(t *testing.T) {
+	f, err := sbf.NewStableBloomFilter(64, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer f.StopDecay()
+
+	for i := 0; i < 1000; i++ {
+		f.Add([]byte{byte(i), byte(i >> 8)})
+	}
+
+	var got map[string]*float64
+	if err := json.Unmarshal([]byte(New(f).String()), &got); err != nil {
+		t.Fatalf("String did not produce valid JSON for a saturated filter: %v", err)
+	}
+	if v, ok := got[sbf.MetricEstimatedItems]; !ok || v != nil {
+		t.Errorf("Expected %s to be null, got %v", sbf.MetricEstimatedItems, v)
+	}
+}
+
+// publishRuns makes the published name unique per run, since expvar names cannot be reused.
+var publishRuns atomic.Int32
+
+func TestPublish(t *testing.T) {
+	f, err := sbf.NewStableBloomFilter(1024, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer f.StopDecay()
+
+	name := fmt.Sprintf("sbfexpvar_test_filter_%d", publishRuns.Add(1))
+	v := Publish(name, f)
+	if expvar.Get(name) != v {
+		t.Error("Publish did not register the variable")
+	}
+}
@@ -0,0 +1,88 @@
+// Package sbfotel bridges Stable Bloom Filter statistics to OpenTelemetry metrics.
+//
+// Gauges and counters are exposed as observable instruments read from the filter's Stats at
+// collection time, and decay passes are recorded as histograms. Instrument names are the metric
+// names defined by the sbf package, so they match the Prometheus and expvar exporters.
+package sbfotel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/Alfex4936/sbf-go"
+)
+
+// FilterAttribute is the attribute key carrying the filter name on every observation.
+const FilterAttribute = "filter"
+
+// Registration is a filter registered with a meter.
+type Registration struct {
+	registration metric.Registration
+	cancelDecay  func()
+}
+
+// Register starts reporting metrics for f through meter, labeled with the given filter name.
+//
+// Returns:
+//   - A Registration that stops reporting when unregistered.
+//   - An error if an instrument cannot be created.
+func Register(meter metric.Meter, name string, f *sbf.StableBloomFilter) (*Registration, error) {
+	metrics := sbf.Stats{}.Metrics()
+	observables := make([]metric.Observable, len(metrics))
+	for i, m := range metrics {
+		var err error
+		switch m.Kind {
+		case sbf.CounterMetric:
+			observables[i], err = meter.Float64ObservableCounter(m.Name, metric.WithDescription(m.Help))
+		default:
+			observables[i], err = meter.Float64ObservableGauge(m.Name, metric.WithDescription(m.Help))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	decayDuration, err := meter.Float64Histogram(sbf.MetricDecayDuration,
+		metric.WithDescription("Duration of decay passes."), metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	decayCleared, err := meter.Int64Histogram(sbf.MetricDecayClearedBits,
+		metric.WithDescription("Number of bits cleared per decay pass."))
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := metric.WithAttributes(attribute.String(FilterAttribute, name))
+
+	reg, err := meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for i, m := range f.Stats().Metrics() {
+			switch obs := observables[i].(type) {
+			case metric.Float64ObservableCounter:
+				o.ObserveFloat64(obs, m.Value, attrs)
+			case metric.Float64ObservableGauge:
+				o.ObserveFloat64(obs, m.Value, attrs)
+			}
+		}
+		return nil
+	}, observables...)
+	if err != nil {
+		return nil, err
+	}
+
+	cancelDecay := f.OnDecay(func(e sbf.DecayEvent) {
+		ctx := context.Background()
+		decayDuration.Record(ctx, e.Duration.Seconds(), attrs)
+		decayCleared.Record(ctx, int64(e.Cleared), attrs)
+	})
+
+	return &Registration{registration: reg, cancelDecay: cancelDecay}, nil
+}
+
+// Unregister stops reporting metrics for the filter.
+func (r *Registration) Unregister() error {
+	r.cancelDecay()
+	return r.registration.Unregister()
+}
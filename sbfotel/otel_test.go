@@ -0,0 +1,100 @@
+package sbfotel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/Alfex4936/sbf-go"
+)
+
+func collect(t *testing.T, reader *sdkmetric.ManualReader) map[string]metricdata.Aggregation {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	got := make(map[string]metricdata.Aggregation)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			got[m.Name] = m.Data
+		}
+	}
+	return got
+}
+
+func TestRegisterObservesStats(t *testing.T) {
+	f, err := sbf.NewStableBloomFilter(1024, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer f.StopDecay()
+
+	f.Add([]byte("element"))
+	f.Add([]byte("other"))
+
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer provider.Shutdown(context.Background())
+
+	reg, err := Register(provider.Meter("sbf"), "dedup", f)
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	got := collect(t, reader)
+	for _, m := range f.Stats().Metrics() {
+		if _, ok := got[m.Name]; !ok {
+			t.Errorf("Metric %s was not reported", m.Name)
+		}
+	}
+
+	adds, ok := got[sbf.MetricAdds].(metricdata.Sum[float64])
+	if !ok || len(adds.DataPoints) != 1 {
+		t.Fatalf("Expected one %s data point, got %#v", sbf.MetricAdds, got[sbf.MetricAdds])
+	}
+	if v := adds.DataPoints[0].Value; v != 2 {
+		t.Errorf("Expected %s to be 2, got %f", sbf.MetricAdds, v)
+	}
+	if name, _ := adds.DataPoints[0].Attributes.Value(FilterAttribute); name.AsString() != "dedup" {
+		t.Errorf("Expected filter attribute dedup, got %q", name.AsString())
+	}
+
+	if err := reg.Unregister(); err != nil {
+		t.Fatalf("Unregister failed: %v", err)
+	}
+	if got := collect(t, reader); len(got) != 0 {
+		t.Errorf("Expected no metrics after Unregister, got %d", len(got))
+	}
+}
+
+func TestRegisterRecordsDecay(t *testing.T) {
+	f, err := sbf.NewStableBloomFilter(1024, nil, 1.0, time.Millisecond*5)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer f.StopDecay()
+
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer provider.Shutdown(context.Background())
+
+	reg, err := Register(provider.Meter("sbf"), "decaying", f)
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	defer reg.Unregister()
+
+	deadline := time.Now().Add(time.Second)
+	for f.Stats().DecayRuns == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	hist, ok := collect(t, reader)[sbf.MetricDecayDuration].(metricdata.Histogram[float64])
+	if !ok || len(hist.DataPoints) != 1 || hist.DataPoints[0].Count == 0 {
+		t.Errorf("Expected decay duration observations, got %#v", hist)
+	}
+}
@@ -0,0 +1,209 @@
+package sbf
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Growth parameters of ScalableFilter, following Almeida et al., "Scalable Bloom Filters" (2007).
+const (
+	scalableGrowth       = 2       // Capacity multiplier of each new slice
+	scalableTightening   = 0.8     // False positive rate multiplier of each new slice
+	scalableFillLimit    = 0.5     // Fill ratio of the newest slice above which a slice is added
+	scalableMaxItems     = 1 << 26 // Largest slice capacity, keeping slice sizes within uint32
+	scalableChecksPerCap = 64      // Fill ratio checks per slice capacity worth of Adds
+)
+
+// ScalableFilter is a Stable Bloom Filter that grows with the number of recent elements.
+//
+// It is a list of StableBloomFilter slices. Add writes to the newest slice, and when its fill
+// ratio rises above one half a new slice with twice the capacity and a tighter false positive rate
+// is appended, so the overall false positive rate stays bounded by the target however many items
+// arrive. Check tests every slice. Older slices keep decaying, and are retired once decay has
+// cleared them.
+//
+// It is safe for concurrent use by multiple goroutines.
+type ScalableFilter struct {
+	decayRate     float64
+	decayInterval time.Duration
+
+	mu      sync.RWMutex
+	slices  []*StableBloomFilter // Oldest first; the last slice receives Adds
+	nextCap uint32               // Capacity of the next slice
+	nextFPR float64              // False positive rate of the next slice
+	cancel  []func()             // Hook cancellations of each slice
+	retired uint64               // Number of slices retired after decaying empty
+	stopped bool
+}
+
+// NewScalableFilter creates a Scalable Stable Bloom Filter.
+//
+// The first slice is sized for initialItems at a false positive rate of 0.2 × falsePositiveRate,
+// and each following slice doubles the capacity at 0.8 times the previous rate, so the compound
+// false positive rate stays below falsePositiveRate.
+//
+// Parameters:
+//   - initialItems: Expected number of items of the first slice.
+//   - falsePositiveRate: Target false positive rate of the whole filter (between 0 and 1).
+//   - decayRate: Probability of decaying bits during each decay interval (between 0 and 1). If zero, defaults to 0.01.
+//   - decayInterval: Time duration between decay operations. If zero, defaults to 1 minute.
+//
+// Returns:
+//   - A pointer to the ScalableFilter.
+//   - An error if the parameters are invalid.
+func NewScalableFilter(initialItems uint32, falsePositiveRate float64, decayRate float64, decayInterval time.Duration) (*ScalableFilter, error) {
+	if initialItems == 0 {
+		return nil, errors.New("expected number of items n must be greater than 0")
+	}
+	if falsePositiveRate <= 0.0 || falsePositiveRate >= 1.0 {
+		return nil, errors.New("false positive rate p must be between 0 and 1 (exclusive)")
+	}
+	if decayRate == 0 {
+		decayRate = 0.01
+	}
+	if decayInterval == 0 {
+		decayInterval = time.Minute
+	}
+
+	s := &ScalableFilter{
+		decayRate:     decayRate,
+		decayInterval: decayInterval,
+		nextCap:       min(initialItems, scalableMaxItems),
+		// The rates p0·r^i sum to p0/(1-r), so p0 = p(1-r) keeps the total below p
+		nextFPR: falsePositiveRate * (1 - scalableTightening),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.growLocked(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add inserts an element into the newest slice.
+func (s *ScalableFilter) Add(data []byte) {
+	s.mu.RLock()
+	newest := s.slices[len(s.slices)-1]
+	s.mu.RUnlock()
+	newest.Add(data)
+}
+
+// Check tests if an element might be in any slice.
+//
+// Returns true if the element might be in the filter, or false if the element is definitely not in the filter.
+func (s *ScalableFilter) Check(data []byte) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := len(s.slices) - 1; i >= 0; i-- {
+		if s.slices[i].Check(data) {
+			return true
+		}
+	}
+	return false
+}
+
+// EstimateFalsePositiveRate estimates the current false positive rate of the ScalableFilter.
+//
+// An element is reported present if any slice reports it, so the estimate is 1 - ∏(1 - pᵢ),
+// where pᵢ is the estimated false positive rate of slice i.
+func (s *ScalableFilter) EstimateFalsePositiveRate() float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	miss := 1.0
+	for _, slice := range s.slices {
+		miss *= 1 - slice.EstimateFalsePositiveRate()
+	}
+	return 1 - miss
+}
+
+// Slices returns the number of live slices.
+func (s *ScalableFilter) Slices() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.slices)
+}
+
+// Retired returns the number of slices that were removed after decaying empty.
+func (s *ScalableFilter) Retired() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.retired
+}
+
+// StopDecay stops the decay process of every slice.
+//
+// This function should be called when the filter is no longer needed to clean up resources.
+func (s *ScalableFilter) StopDecay() {
+	s.mu.Lock()
+	s.stopped = true
+	slices, cancel := s.slices, s.cancel
+	s.mu.Unlock()
+
+	for i, slice := range slices {
+		cancel[i]()
+		slice.StopDecay()
+	}
+}
+
+// grow appends a new slice once the newest slice has filled up.
+func (s *ScalableFilter) grow(full *StableBloomFilter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped || s.slices[len(s.slices)-1] != full {
+		return
+	}
+	// A slice that cannot be created leaves the newest slice in place; it keeps working at a
+	// higher false positive rate
+	_ = s.growLocked()
+}
+
+// growLocked creates the next slice and registers its hooks. s.mu must be held.
+func (s *ScalableFilter) growLocked() error {
+	slice, err := NewDefaultStableBloomFilter(s.nextCap, s.nextFPR, s.decayRate, s.decayInterval)
+	if err != nil {
+		return err
+	}
+	slice.SetThresholdCheckInterval(max(uint64(s.nextCap)/scalableChecksPerCap, 1))
+
+	cancelFill := slice.OnFillRatioAbove(scalableFillLimit, func(Stats) { s.grow(slice) })
+	cancelDecay := slice.OnDecay(func(DecayEvent) { s.retireIfEmpty(slice) })
+	s.slices = append(s.slices, slice)
+	s.cancel = append(s.cancel, func() {
+		cancelFill()
+		cancelDecay()
+	})
+
+	s.nextCap = min(s.nextCap*scalableGrowth, scalableMaxItems)
+	s.nextFPR *= scalableTightening
+	return nil
+}
+
+// retireIfEmpty removes a slice other than the newest once decay has cleared all of its bits.
+//
+// It runs on the slice's decay goroutine, so the slice is stopped on another goroutine.
+func (s *ScalableFilter) retireIfEmpty(slice *StableBloomFilter) {
+	if slice.countBitsSet() != 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return
+	}
+	for i := 0; i < len(s.slices)-1; i++ {
+		if s.slices[i] == slice {
+			cancel := s.cancel[i]
+			s.slices = append(s.slices[:i:i], s.slices[i+1:]...)
+			s.cancel = append(s.cancel[:i:i], s.cancel[i+1:]...)
+			s.retired++
+			go func() {
+				cancel()
+				slice.StopDecay()
+			}()
+			return
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package sbf
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestScalableFilterGrows(t *testing.T) {
+	s, err := NewScalableFilter(100, 0.01, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create ScalableFilter: %v", err)
+	}
+	defer s.StopDecay()
+
+	const n = 5000
+	for i := 0; i < n; i++ {
+		s.Add([]byte(fmt.Sprintf("element_%d", i)))
+	}
+	if s.Slices() < 4 {
+		t.Errorf("Expected the filter to grow to at least 4 slices, got %d", s.Slices())
+	}
+	for i := 0; i < n; i++ {
+		if !s.Check([]byte(fmt.Sprintf("element_%d", i))) {
+			t.Fatalf("element_%d missing after growth", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if s.Check([]byte(fmt.Sprintf("absent_%d", i))) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / 10000; rate > 0.02 {
+		t.Errorf("False positive rate %.4f exceeds twice the 0.01 target", rate)
+	}
+	if fpr := s.EstimateFalsePositiveRate(); fpr <= 0 || fpr > 0.02 {
+		t.Errorf("Estimated FPR %.4f out of range", fpr)
+	}
+}
+
+func TestScalableFilterRetiresEmptySlices(t *testing.T) {
+	s, err := NewScalableFilter(100, 0.01, 1.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create ScalableFilter: %v", err)
+	}
+	defer s.StopDecay()
+
+	for i := 0; s.Slices() < 2; i++ {
+		if i > 10000 {
+			t.Fatal("Filter never grew")
+		}
+		s.Add([]byte(fmt.Sprintf("element_%d", i)))
+	}
+
+	s.mu.RLock()
+	oldest, newest := s.slices[0], s.slices[len(s.slices)-1]
+	s.mu.RUnlock()
+
+	// Decaying the newest slice empty does not retire it
+	newest.decay()
+	oldest.decay()
+	if s.Retired() != 1 {
+		t.Fatalf("Expected 1 retired slice, got %d", s.Retired())
+	}
+	if s.Slices() != 1 {
+		t.Errorf("Expected 1 live slice, got %d", s.Slices())
+	}
+	s.Add([]byte("after"))
+	if !s.Check([]byte("after")) {
+		t.Error("Element missing after a slice was retired")
+	}
+}
+
+func TestScalableFilterInvalidParameters(t *testing.T) {
+	if _, err := NewScalableFilter(0, 0.01, 0.01, time.Minute); err == nil {
+		t.Error("Expected an error for zero initial items")
+	}
+	if _, err := NewScalableFilter(100, 1.0, 0.01, time.Minute); err == nil {
+		t.Error("Expected an error for an invalid false positive rate")
+	}
+}
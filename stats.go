@@ -0,0 +1,144 @@
+package sbf
+
+import (
+	"math"
+	"math/bits"
+	"math/rand"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a point-in-time snapshot of a Stable Bloom Filter's state and activity.
+//
+// The snapshot is not taken atomically with respect to concurrent Add, Check or decay
+// operations, so the values may be slightly out of sync with each other under load.
+type Stats struct {
+	M                    uint32        // Size of the filter (number of bits)
+	K                    uint32        // Number of hash functions
+	Bytes                uint64        // Memory used by the bit array in bytes
+	BitsSet              uint64        // Number of bits currently set
+	FillRatio            float64       // Fraction of bits currently set
+	EstimatedFPR         float64       // Estimated false positive rate
+	EstimatedCardinality float64       // Estimated number of distinct items in the filter
+	DecayRuns            uint64        // Number of completed decay passes
+	LastDecayDuration    time.Duration // Duration of the most recent decay pass
+	LastDecayCleared     uint64        // Bits cleared by the most recent decay pass
+	DecayCleared         uint64        // Cumulative number of bits cleared by decay
+	Adds                 uint64        // Cumulative number of Add calls
+	Checks               uint64        // Cumulative number of Check calls
+}
+
+// Stats returns a snapshot of the filter's statistics.
+//
+// It scans the whole bit array, so its cost is proportional to the filter size.
+func (sbf *StableBloomFilter) Stats() Stats {
+	bitsSet := sbf.countBitsSet()
+	fillRatio := float64(bitsSet) / float64(sbf.m)
+	estimatedFPR := math.Pow(fillRatio, float64(sbf.k))
+	if sbf.layout == LayoutPartitioned {
+		estimatedFPR = sbf.partitionedFPR()
+	}
+
+	return Stats{
+		M:                    sbf.m,
+		K:                    sbf.k,
+		Bytes:                uint64(sbf.numBuckets) * 8,
+		BitsSet:              bitsSet,
+		FillRatio:            fillRatio,
+		EstimatedFPR:         estimatedFPR,
+		EstimatedCardinality: estimateCardinality(bitsSet, sbf.m, sbf.k),
+		DecayRuns:            sbf.decayRuns.Load(),
+		LastDecayDuration:    time.Duration(sbf.lastDecayNanos.Load()),
+		LastDecayCleared:     sbf.lastDecayCleared.Load(),
+		DecayCleared:         sbf.decayCleared.Load(),
+		Adds:                 sbf.adds.load(),
+		Checks:               sbf.checks.load(),
+	}
+}
+
+// countBitsSet returns the number of bits currently set in the filter.
+func (sbf *StableBloomFilter) countBitsSet() uint64 {
+	var bitsSet uint64
+	for i := range sbf.filter {
+		bitsSet += uint64(bits.OnesCount64(atomic.LoadUint64(&sbf.filter[i])))
+	}
+	return bitsSet
+}
+
+// estimateCardinality estimates the number of distinct items from the number of set bits
+// using the Swamidass–Baldi estimator -m/k * ln(1 - X/m).
+func estimateCardinality(bitsSet uint64, m, k uint32) float64 {
+	if bitsSet >= uint64(m) {
+		return math.Inf(1)
+	}
+	return -float64(m) / float64(k) * math.Log1p(-float64(bitsSet)/float64(m))
+}
+
+// cardinalityBounds returns a confidence interval around the Swamidass–Baldi estimate.
+//
+// The number of zero bits Z left after kn hash insertions has the occupancy variance
+// m(m-1)(1-2/m)^kn + m(1-1/m)^kn - m²(1-1/m)^2kn. It is evaluated at the estimated n and
+// propagated through the estimator with the delta method, |dn/dZ| = m / (kZ).
+func cardinalityBounds(bitsSet uint64, m, k uint32, confidence float64) (lower, estimate, upper float64) {
+	if bitsSet >= uint64(m) {
+		// Saturated: at least as many items as it takes to leave a single zero bit
+		return estimateCardinality(uint64(m)-1, m, k), math.Inf(1), math.Inf(1)
+	}
+
+	estimate = estimateCardinality(bitsSet, m, k)
+	fm := float64(m)
+	kn := float64(k) * estimate
+	zeros := fm - float64(bitsSet)
+
+	variance := fm*(fm-1)*math.Pow(1-2/fm, kn) + fm*math.Pow(1-1/fm, kn) - fm*fm*math.Pow(1-1/fm, 2*kn)
+	if variance < 0 {
+		variance = 0 // Rounding error when the filter is nearly empty
+	}
+	stdDev := math.Sqrt(variance) * fm / (float64(k) * zeros)
+	z := math.Sqrt2 * math.Erfinv(confidence)
+
+	lower = math.Max(estimate-z*stdDev, float64(bitsSet)/float64(k))
+	upper = estimate + z*stdDev
+	return lower, estimate, upper
+}
+
+// paddedCounter is a counter padded to a cache line to avoid false sharing.
+type paddedCounter struct {
+	n atomic.Uint64
+	_ [56]byte
+}
+
+// shardedCounter is a low-contention counter spread over one padded slot per CPU.
+//
+// Increments pick a slot pseudo-randomly, so concurrent writers rarely touch the same cache line.
+type shardedCounter struct {
+	slots []paddedCounter
+	mask  uint32
+}
+
+// newShardedCounter creates a sharded counter sized to the number of usable CPUs.
+func newShardedCounter() shardedCounter {
+	n := uint32(1)
+	for n < uint32(runtime.GOMAXPROCS(0)) {
+		n <<= 1
+	}
+	return shardedCounter{
+		slots: make([]paddedCounter, n),
+		mask:  n - 1,
+	}
+}
+
+// inc increments the counter by one.
+func (c *shardedCounter) inc() {
+	c.slots[rand.Uint32()&c.mask].n.Add(1)
+}
+
+// load returns the sum of all slots.
+func (c *shardedCounter) load() uint64 {
+	var sum uint64
+	for i := range c.slots {
+		sum += c.slots[i].n.Load()
+	}
+	return sum
+}
@@ -0,0 +1,264 @@
+package sbf
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStatsEmptyFilter(t *testing.T) {
+	sbf, err := NewStableBloomFilter(1024, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+
+	stats := sbf.Stats()
+	if stats.M != 1024 || stats.K != 7 {
+		t.Errorf("Expected m=1024 k=7, got m=%d k=%d", stats.M, stats.K)
+	}
+	if stats.Bytes != 128 {
+		t.Errorf("Expected 128 bytes, got %d", stats.Bytes)
+	}
+	if stats.BitsSet != 0 || stats.FillRatio != 0 || stats.EstimatedFPR != 0 || stats.EstimatedCardinality != 0 {
+		t.Errorf("Expected empty filter stats, got %+v", stats)
+	}
+}
+
+func TestStatsAfterAdditions(t *testing.T) {
+	sbf, err := NewDefaultStableBloomFilter(1000, 0.01, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+
+	numElements := 500
+	for i := 0; i < numElements; i++ {
+		sbf.Add([]byte(fmt.Sprintf("element%d", i)))
+	}
+	for i := 0; i < 200; i++ {
+		sbf.Check([]byte(fmt.Sprintf("element%d", i)))
+	}
+
+	stats := sbf.Stats()
+	if stats.Adds != uint64(numElements) {
+		t.Errorf("Expected %d adds, got %d", numElements, stats.Adds)
+	}
+	if stats.Checks != 200 {
+		t.Errorf("Expected 200 checks, got %d", stats.Checks)
+	}
+	if stats.FillRatio != float64(stats.BitsSet)/float64(stats.M) {
+		t.Errorf("Fill ratio %f does not match bits set %d", stats.FillRatio, stats.BitsSet)
+	}
+	if stats.EstimatedFPR != sbf.EstimateFalsePositiveRate() {
+		t.Errorf("Expected FPR %f, got %f", sbf.EstimateFalsePositiveRate(), stats.EstimatedFPR)
+	}
+	if math.Abs(stats.EstimatedCardinality-float64(numElements)) > float64(numElements)*0.05 {
+		t.Errorf("Expected cardinality close to %d, got %f", numElements, stats.EstimatedCardinality)
+	}
+}
+
+func TestStatsDecay(t *testing.T) {
+	sbf, err := NewStableBloomFilter(1024, nil, 1.0, time.Millisecond*10)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+
+	for i := range sbf.filter {
+		atomic.StoreUint64(&sbf.filter[i], ^uint64(0))
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for sbf.Stats().DecayRuns == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	stats := sbf.Stats()
+	if stats.DecayRuns == 0 {
+		t.Fatal("Expected at least one decay run")
+	}
+	if stats.LastDecayDuration <= 0 {
+		t.Errorf("Expected a positive decay duration, got %v", stats.LastDecayDuration)
+	}
+	if stats.DecayRuns == 1 && stats.LastDecayCleared != 1024 {
+		t.Errorf("Expected first decay to clear 1024 bits, got %d", stats.LastDecayCleared)
+	}
+}
+
+func TestDecayBucketPartial(t *testing.T) {
+	randSrc := rand.New(rand.NewSource(42))
+
+	if got := decayBucket(^uint64(0), 0.0, randSrc); got != ^uint64(0) {
+		t.Errorf("Expected no bits decayed with rate 0, got %064b", got)
+	}
+	if got := decayBucket(^uint64(0), 1.0, randSrc); got != 0 {
+		t.Errorf("Expected all bits decayed with rate 1, got %064b", got)
+	}
+
+	// Bits that were not set must never become set
+	bucket := uint64(0xF0F0F0F0F0F0F0F0)
+	if got := decayBucket(bucket, 0.5, randSrc); got&^bucket != 0 {
+		t.Errorf("Decay set new bits: %064b", got)
+	}
+}
+
+func TestShardedCounterConcurrent(t *testing.T) {
+	c := newShardedCounter()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				c.inc()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := c.load(); got != 8000 {
+		t.Errorf("Expected 8000, got %d", got)
+	}
+}
+
+func TestStatsMetrics(t *testing.T) {
+	sbf, err := NewStableBloomFilter(1024, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+
+	sbf.Add([]byte("element"))
+
+	names := make(map[string]bool)
+	empty := Stats{}.Metrics()
+	for i, m := range sbf.Stats().Metrics() {
+		if names[m.Name] {
+			t.Errorf("Duplicate metric name %s", m.Name)
+		}
+		names[m.Name] = true
+		if empty[i].Name != m.Name || empty[i].Kind != m.Kind {
+			t.Errorf("Metric %d differs between snapshots: %s and %s", i, empty[i].Name, m.Name)
+		}
+		if m.Name == MetricAdds && m.Value != 1 {
+			t.Errorf("Expected %s to be 1, got %f", MetricAdds, m.Value)
+		}
+	}
+}
+
+func TestMetricFamilyNamesDistinct(t *testing.T) {
+	// OpenMetrics names a counter family without its _total suffix, and a histogram family owns
+	// its _bucket, _sum and _count series, so these must not collide with other metrics.
+	families := make(map[string]string)
+	series := make(map[string]string)
+	add := func(names map[string]string, name, owner string) {
+		if other, ok := names[name]; ok {
+			t.Errorf("Metric %s clashes with %s on %s", owner, other, name)
+		}
+		names[name] = owner
+	}
+	for _, m := range (Stats{}).Metrics() {
+		family := m.Name
+		if m.Kind == CounterMetric {
+			family = strings.TrimSuffix(m.Name, "_total")
+		}
+		add(families, family, m.Name)
+		add(series, m.Name, m.Name)
+	}
+	for _, name := range []string{MetricDecayDuration, MetricDecayClearedBits} {
+		add(families, name, name)
+		for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+			add(series, name+suffix, name)
+		}
+	}
+}
+
+func TestEstimateCount(t *testing.T) {
+	sbf, err := NewDefaultStableBloomFilter(1000, 0.01, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+
+	if got := sbf.EstimateCount(); got != 0 {
+		t.Errorf("Expected 0 for an empty filter, got %f", got)
+	}
+
+	numElements := 1000
+	for i := 0; i < numElements; i++ {
+		sbf.Add([]byte(fmt.Sprintf("element%d", i)))
+	}
+
+	got := sbf.EstimateCount()
+	if math.Abs(got-float64(numElements)) > float64(numElements)*0.05 {
+		t.Errorf("Expected count close to %d, got %f", numElements, got)
+	}
+	if got != sbf.Stats().EstimatedCardinality {
+		t.Errorf("EstimateCount %f does not match Stats %f", got, sbf.Stats().EstimatedCardinality)
+	}
+}
+
+func TestEstimateCountBoundsCoverage(t *testing.T) {
+	const trials = 200
+	const numElements = 300
+
+	covered := 0
+	for trial := 0; trial < trials; trial++ {
+		sbf, err := NewStableBloomFilter(2048, nil, 0.0, time.Hour)
+		if err != nil {
+			t.Fatalf("Failed to create StableBloomFilter: %v", err)
+		}
+		for i := 0; i < numElements; i++ {
+			sbf.Add([]byte(fmt.Sprintf("trial%d_element%d", trial, i)))
+		}
+		lower, estimate, upper, err := sbf.EstimateCountBounds(0.95)
+		sbf.StopDecay()
+		if err != nil {
+			t.Fatalf("EstimateCountBounds failed: %v", err)
+		}
+		if !(lower <= estimate && estimate <= upper) {
+			t.Fatalf("Bounds out of order: %f <= %f <= %f", lower, estimate, upper)
+		}
+		if lower <= numElements && numElements <= upper {
+			covered++
+		}
+	}
+
+	if coverage := float64(covered) / trials; coverage < 0.9 {
+		t.Errorf("Expected about 95%% coverage, got %.2f", coverage)
+	}
+}
+
+func TestEstimateCountBoundsEdgeCases(t *testing.T) {
+	sbf, err := NewStableBloomFilter(1024, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+
+	for _, confidence := range []float64{0, 1, -0.5, 1.5} {
+		if _, _, _, err := sbf.EstimateCountBounds(confidence); err == nil {
+			t.Errorf("Expected an error for confidence %f", confidence)
+		}
+	}
+
+	lower, estimate, upper, _ := sbf.EstimateCountBounds(0.95)
+	if lower != 0 || estimate != 0 || upper != 0 {
+		t.Errorf("Expected zero bounds for an empty filter, got %f %f %f", lower, estimate, upper)
+	}
+
+	for i := range sbf.filter {
+		atomic.StoreUint64(&sbf.filter[i], ^uint64(0))
+	}
+	lower, estimate, upper, _ = sbf.EstimateCountBounds(0.95)
+	if math.IsInf(lower, 0) || !math.IsInf(estimate, 1) || !math.IsInf(upper, 1) {
+		t.Errorf("Expected finite lower and infinite estimate for a saturated filter, got %f %f %f", lower, estimate, upper)
+	}
+}
@@ -0,0 +1,243 @@
+package sbf
+
+import (
+	"errors"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// Timing Bloom filter cell layout: four 16-bit timestamps per uint64 word. A timestamp is the
+// time slot of the last Add that hashed to the cell, wrapped into 1..timingStamps; 0 marks an
+// empty cell.
+const (
+	cellsPerWord  = 4
+	cellBits      = 16
+	cellMask      = 1<<cellBits - 1
+	timingStamps  = cellMask     // Number of distinct non-zero timestamps
+	maxTimingTTL  = cellMask / 2 // Largest TTL in slots that keeps wrapped ages unambiguous
+	defaultTTLRes = 256          // Default number of slots per TTL
+)
+
+// TimingBloomFilter is a Bloom filter whose elements expire after a fixed time to live.
+//
+// Each of its m cells holds a coarse 16-bit timestamp instead of a bit. Add stamps the k cells of
+// an element with the current time slot, and Check reports an element only if all k cells were
+// stamped within the TTL. A background sweep clears expired cells so that wrapped timestamps are
+// never mistaken for recent ones.
+//
+// It is safe for concurrent use by multiple goroutines.
+type TimingBloomFilter struct {
+	m          uint32   // Number of cells
+	k          uint32   // Number of hash functions
+	hashFuncs  []Hash64 // Slice of hash functions
+	cells      []uint64 // Cells packed four per word
+	ttl        time.Duration
+	resolution time.Duration // Length of a time slot
+	ttlSlots   uint64        // TTL in slots
+	epoch      time.Time
+	now        func() time.Time
+
+	sweeps  atomic.Uint64 // Number of completed sweeps
+	cleared atomic.Uint64 // Cumulative number of cells cleared by sweeps
+	sweeper periodic
+}
+
+// NewTimingBloomFilter creates a Timing Bloom Filter whose elements expire after ttl.
+//
+// Time is tracked in slots of the given resolution and the TTL is rounded up to whole slots, so an
+// element is reported for at least ttl and less than ttl+2*resolution after it was last added. When
+// ttl is a multiple of resolution, the upper bound tightens to ttl+resolution. If hashFuncs is
+// empty, it uses default hash functions based on zeebo/xxh3.
+//
+// Parameters:
+//   - m: Number of cells. Each cell takes 16 bits.
+//   - hashFuncs: Slice of hash functions to use. If empty, default hash functions are used.
+//   - ttl: Time an element is remembered after it was last added.
+//   - resolution: Length of a time slot. If zero, defaults to ttl/256. Must be at least ttl/32767.
+//
+// Returns:
+//   - A pointer to the TimingBloomFilter.
+//   - An error if the parameters are invalid.
+func NewTimingBloomFilter(m uint32, hashFuncs []Hash64, ttl, resolution time.Duration) (*TimingBloomFilter, error) {
+	if m == 0 {
+		return nil, errors.New("filter size m must be greater than 0")
+	}
+	if ttl <= 0 {
+		return nil, errors.New("ttl must be positive")
+	}
+	if resolution == 0 {
+		resolution = ttl / defaultTTLRes
+	}
+	if resolution <= 0 {
+		return nil, errors.New("resolution must be positive")
+	}
+	ttlSlots := uint64((ttl + resolution - 1) / resolution)
+	if ttlSlots > maxTimingTTL {
+		return nil, errors.New("resolution is too fine for the ttl")
+	}
+	if len(hashFuncs) == 0 {
+		hashFuncs, _ = defaultHashFuncs(7)
+	}
+
+	// Ensure m fills whole words
+	if m%cellsPerWord != 0 {
+		m += cellsPerWord - (m % cellsPerWord)
+	}
+
+	t := &TimingBloomFilter{
+		m:          m,
+		k:          uint32(len(hashFuncs)),
+		hashFuncs:  hashFuncs,
+		cells:      make([]uint64, m/cellsPerWord),
+		ttl:        ttl,
+		resolution: resolution,
+		ttlSlots:   ttlSlots,
+		epoch:      time.Now(),
+		now:        time.Now,
+	}
+
+	// Sweeping twice per TTL clears every cell before its age reaches 2*ttlSlots < timingStamps
+	sweepInterval := ttl / 2
+	if sweepInterval < resolution {
+		sweepInterval = resolution
+	}
+	t.sweeper.start(sweepInterval, func() { t.Sweep() })
+	return t, nil
+}
+
+// Add inserts an element, or refreshes its TTL if it is already present.
+func (t *TimingBloomFilter) Add(data []byte) {
+	stamp := t.stamp()
+	for i := uint32(0); i < t.k; i++ {
+		t.storeCell(t.hashIndex(data, i), stamp)
+	}
+}
+
+// Check tests if an element might have been added within the TTL.
+//
+// Returns true if the element might be in the filter, or false if the element is definitely not in the filter.
+func (t *TimingBloomFilter) Check(data []byte) bool {
+	stamp := t.stamp()
+	for i := uint32(0); i < t.k; i++ {
+		if !t.live(t.loadCell(t.hashIndex(data, i)), stamp) {
+			return false
+		}
+	}
+	return true
+}
+
+// Sweep clears the cells whose timestamps have expired.
+//
+// It runs automatically in the background; calling it directly is only needed to reclaim cells
+// at a specific moment.
+//
+// Returns:
+//   - The number of cells cleared.
+func (t *TimingBloomFilter) Sweep() uint64 {
+	stamp := t.stamp()
+	var n uint64
+	for i := range t.cells {
+		for {
+			old := atomic.LoadUint64(&t.cells[i])
+			word := old
+			for c := 0; c < cellsPerWord; c++ {
+				shift := uint(c * cellBits)
+				if cell := (word >> shift) & cellMask; cell != 0 && !t.live(cell, stamp) {
+					word &^= cellMask << shift
+				}
+			}
+			if word == old {
+				break
+			}
+			if atomic.CompareAndSwapUint64(&t.cells[i], old, word) {
+				n += uint64(countCells(old) - countCells(word))
+				break
+			}
+		}
+	}
+	t.cleared.Add(n)
+	t.sweeps.Add(1)
+	return n
+}
+
+// EstimateFalsePositiveRate estimates the current false positive rate of the TimingBloomFilter.
+//
+// The estimation is based on the fraction of cells holding a live timestamp and the number of hash functions.
+func (t *TimingBloomFilter) EstimateFalsePositiveRate() float64 {
+	stamp := t.stamp()
+	var live uint64
+	for i := range t.cells {
+		word := atomic.LoadUint64(&t.cells[i])
+		for c := 0; c < cellsPerWord; c++ {
+			if t.live((word>>uint(c*cellBits))&cellMask, stamp) {
+				live++
+			}
+		}
+	}
+	return math.Pow(float64(live)/float64(t.m), float64(t.k))
+}
+
+// TTL returns the time an element is remembered after it was last added.
+func (t *TimingBloomFilter) TTL() time.Duration {
+	return t.ttl
+}
+
+// StopDecay stops the background sweep of the TimingBloomFilter.
+//
+// This function should be called when the filter is no longer needed to clean up resources.
+// Expired elements are still reported absent after it is called, but their cells are no longer
+// reclaimed, so the filter must not be used for longer than about 32767 time slots afterwards.
+func (t *TimingBloomFilter) StopDecay() {
+	t.sweeper.stop()
+}
+
+// stamp returns the wrapped timestamp of the current time slot, in 1..timingStamps.
+func (t *TimingBloomFilter) stamp() uint64 {
+	slot := uint64(t.now().Sub(t.epoch) / t.resolution)
+	return slot%timingStamps + 1
+}
+
+// live reports whether a cell holds a timestamp within the TTL of the current stamp.
+func (t *TimingBloomFilter) live(cell, stamp uint64) bool {
+	if cell == 0 {
+		return false
+	}
+	age := (stamp + timingStamps - cell) % timingStamps
+	return age <= t.ttlSlots
+}
+
+// loadCell returns the timestamp in cell idx.
+func (t *TimingBloomFilter) loadCell(idx uint32) uint64 {
+	word := atomic.LoadUint64(&t.cells[idx/cellsPerWord])
+	return (word >> (idx % cellsPerWord * cellBits)) & cellMask
+}
+
+// storeCell sets cell idx to stamp atomically.
+func (t *TimingBloomFilter) storeCell(idx uint32, stamp uint64) {
+	addr := &t.cells[idx/cellsPerWord]
+	shift := idx % cellsPerWord * cellBits
+	for {
+		old := atomic.LoadUint64(addr)
+		word := old&^(cellMask<<shift) | stamp<<shift
+		if word == old || atomic.CompareAndSwapUint64(addr, old, word) {
+			return
+		}
+	}
+}
+
+// hashIndex computes the cell index for the i-th hash function.
+func (t *TimingBloomFilter) hashIndex(data []byte, i uint32) uint32 {
+	return uint32(t.hashFuncs[i](data) % uint64(t.m))
+}
+
+// countCells returns the number of non-empty cells in a word.
+func countCells(word uint64) int {
+	n := 0
+	for c := 0; c < cellsPerWord; c++ {
+		if (word>>uint(c*cellBits))&cellMask != 0 {
+			n++
+		}
+	}
+	return n
+}
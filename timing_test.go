@@ -0,0 +1,140 @@
+package sbf
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock for TimingBloomFilter tests.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func newTestTimingFilter(t *testing.T, ttl, resolution time.Duration) (*TimingBloomFilter, *fakeClock) {
+	t.Helper()
+	f, err := NewTimingBloomFilter(4096, nil, ttl, resolution)
+	if err != nil {
+		t.Fatalf("Failed to create TimingBloomFilter: %v", err)
+	}
+	f.StopDecay() // Sweeps are run by hand against the fake clock
+	clock := &fakeClock{now: f.epoch}
+	f.now = clock.Now
+	return f, clock
+}
+
+func TestTimingBloomFilterTTL(t *testing.T) {
+	f, clock := newTestTimingFilter(t, time.Minute, time.Second)
+
+	f.Add([]byte("element"))
+	clock.now = clock.now.Add(59 * time.Second)
+	if !f.Check([]byte("element")) {
+		t.Error("Element expired before its TTL")
+	}
+
+	// Adding again refreshes the TTL
+	f.Add([]byte("element"))
+	clock.now = clock.now.Add(59 * time.Second)
+	if !f.Check([]byte("element")) {
+		t.Error("Element expired before its refreshed TTL")
+	}
+
+	clock.now = clock.now.Add(2 * time.Second)
+	if f.Check([]byte("element")) {
+		t.Error("Element still present after its TTL")
+	}
+}
+
+func TestTimingBloomFilterSweep(t *testing.T) {
+	f, clock := newTestTimingFilter(t, time.Minute, time.Second)
+
+	for i := 0; i < 100; i++ {
+		f.Add([]byte(fmt.Sprintf("element_%d", i)))
+	}
+	if fpr := f.EstimateFalsePositiveRate(); fpr <= 0 {
+		t.Errorf("Expected a positive FPR, got %f", fpr)
+	}
+	if n := f.Sweep(); n != 0 {
+		t.Errorf("Sweep cleared %d live cells", n)
+	}
+
+	clock.now = clock.now.Add(2 * time.Minute)
+	if fpr := f.EstimateFalsePositiveRate(); fpr != 0 {
+		t.Errorf("Expected FPR 0 once every element expired, got %f", fpr)
+	}
+	if n := f.Sweep(); n == 0 {
+		t.Error("Sweep cleared no expired cells")
+	}
+	for i := range f.cells {
+		if f.cells[i] != 0 {
+			t.Fatalf("Word %d not cleared by sweep: %#x", i, f.cells[i])
+		}
+	}
+}
+
+func TestTimingBloomFilterWraparound(t *testing.T) {
+	f, clock := newTestTimingFilter(t, time.Minute, time.Second)
+
+	f.Add([]byte("old"))
+	clock.now = clock.now.Add(2 * time.Minute)
+	f.Sweep()
+
+	// Advance past a full wrap of the 16-bit timestamps, sweeping as the background process would
+	for i := 0; i < timingStamps/30+1; i++ {
+		clock.now = clock.now.Add(30 * time.Second)
+		f.Sweep()
+	}
+	f.Add([]byte("new"))
+	if f.Check([]byte("old")) {
+		t.Error("Expired element reported present after timestamps wrapped")
+	}
+	if !f.Check([]byte("new")) {
+		t.Error("Element missing after timestamps wrapped")
+	}
+}
+
+func TestTimingBloomFilterBackgroundSweep(t *testing.T) {
+	f, err := NewTimingBloomFilter(1024, nil, 20*time.Millisecond, time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to create TimingBloomFilter: %v", err)
+	}
+	defer f.StopDecay()
+
+	f.Add([]byte("element"))
+	deadline := time.Now().Add(5 * time.Second)
+	for f.sweeps.Load() == 0 || f.cleared.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Background sweep never cleared the expired element")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if f.Check([]byte("element")) {
+		t.Error("Element present after it was swept")
+	}
+}
+
+func TestTimingBloomFilterInvalidParameters(t *testing.T) {
+	tests := []struct {
+		name       string
+		m          uint32
+		ttl        time.Duration
+		resolution time.Duration
+	}{
+		{name: "zero size", m: 0, ttl: time.Minute},
+		{name: "zero ttl", m: 1024, ttl: 0},
+		{name: "resolution too fine", m: 1024, ttl: time.Hour, resolution: time.Millisecond},
+		{name: "negative resolution", m: 1024, ttl: time.Minute, resolution: -time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewTimingBloomFilter(tt.m, nil, tt.ttl, tt.resolution)
+			if err == nil {
+				f.StopDecay()
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package sbf
+
+import (
+	"container/heap"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HeavyHitter is a key reported by TopK with its approximate recent count.
+type HeavyHitter struct {
+	Key   string
+	Count uint64
+}
+
+// TopK tracks the k most frequent recent keys.
+//
+// Counts come from a decaying CountMinSketch with conservative update, and the current top keys
+// are kept in a min-heap ordered by estimated count, in the spirit of HeavyKeeper. A key enters
+// the heap once its estimate exceeds the smallest count in a full heap. On every decay interval
+// the sketch decays and the heap's counts are refreshed from it, so keys that stop appearing fall
+// out of the top k.
+//
+// It is safe for concurrent use by multiple goroutines.
+type TopK struct {
+	k      int
+	sketch *CountMinSketch
+
+	mu    sync.Mutex
+	heap  hitterHeap
+	index map[string]int // Position of each tracked key in heap
+	decay periodic
+}
+
+// NewTopK creates a tracker of the k most frequent recent keys.
+//
+// Parameters:
+//   - k: Number of keys to track.
+//   - width: Number of counters per row of the underlying count-min sketch.
+//   - depth: Number of rows of the underlying count-min sketch.
+//   - decayFactor: Factor applied to every count on each decay interval (between 0 and 1). If zero, defaults to 0.5.
+//   - decayInterval: Time duration between decay operations. If zero, defaults to 1 minute.
+//
+// Returns:
+//   - A pointer to the TopK.
+//   - An error if the parameters are invalid.
+func NewTopK(k int, width, depth uint32, decayFactor float64, decayInterval time.Duration) (*TopK, error) {
+	if k <= 0 {
+		return nil, errors.New("k must be greater than 0")
+	}
+	decayFactor, decayInterval, err := sketchParams(width, depth, decayFactor, decayInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &TopK{
+		k:      k,
+		sketch: newCountMinSketch(width, depth, decayFactor),
+		index:  make(map[string]int, k),
+	}
+	t.sketch.SetConservativeUpdate(true)
+	t.heap.index = t.index
+	t.decay.start(decayInterval, t.decayCounts)
+	return t, nil
+}
+
+// Add records n occurrences of a key.
+//
+// Returns:
+//   - The key's estimated recent count after the increment.
+func (t *TopK) Add(key []byte, n uint64) uint64 {
+	count := t.sketch.Increment(key, n)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if i, ok := t.index[string(key)]; ok {
+		t.heap.items[i].Count = count
+		heap.Fix(&t.heap, i)
+		return count
+	}
+	if len(t.heap.items) < t.k {
+		heap.Push(&t.heap, HeavyHitter{Key: string(key), Count: count})
+		return count
+	}
+	if count > t.heap.items[0].Count {
+		delete(t.index, t.heap.items[0].Key)
+		t.heap.items[0] = HeavyHitter{Key: string(key), Count: count}
+		t.index[t.heap.items[0].Key] = 0
+		heap.Fix(&t.heap, 0)
+	}
+	return count
+}
+
+// Estimate returns the estimated recent count of a key, whether or not it is in the top k.
+func (t *TopK) Estimate(key []byte) uint64 {
+	return t.sketch.Estimate(key)
+}
+
+// List returns the tracked keys, most frequent first.
+func (t *TopK) List() []HeavyHitter {
+	t.mu.Lock()
+	list := append([]HeavyHitter(nil), t.heap.items...)
+	t.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Key < list[j].Key
+	})
+	return list
+}
+
+// StopDecay stops the decay process of the tracker.
+//
+// This function should be called when the tracker is no longer needed to clean up resources.
+func (t *TopK) StopDecay() {
+	t.decay.stop()
+}
+
+// decayCounts decays the sketch and refreshes the tracked counts, dropping keys that decayed to zero.
+func (t *TopK) decayCounts() {
+	t.sketch.decay()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	items := t.heap.items[:0]
+	for _, item := range t.heap.items {
+		item.Count = t.sketch.Estimate([]byte(item.Key))
+		if item.Count == 0 {
+			delete(t.index, item.Key)
+			continue
+		}
+		items = append(items, item)
+	}
+	t.heap.items = items
+	for i, item := range items {
+		t.index[item.Key] = i
+	}
+	heap.Init(&t.heap)
+}
+
+// hitterHeap is a min-heap of heavy hitters by count that keeps an index of key positions.
+type hitterHeap struct {
+	items []HeavyHitter
+	index map[string]int
+}
+
+func (h *hitterHeap) Len() int           { return len(h.items) }
+func (h *hitterHeap) Less(i, j int) bool { return h.items[i].Count < h.items[j].Count }
+
+func (h *hitterHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.index[h.items[i].Key] = i
+	h.index[h.items[j].Key] = j
+}
+
+func (h *hitterHeap) Push(x any) {
+	item := x.(HeavyHitter)
+	h.index[item.Key] = len(h.items)
+	h.items = append(h.items, item)
+}
+
+func (h *hitterHeap) Pop() any {
+	item := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	delete(h.index, item.Key)
+	return item
+}
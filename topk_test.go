@@ -0,0 +1,80 @@
+package sbf
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTopKFindsHeavyHitters(t *testing.T) {
+	top, err := NewTopK(3, 1024, 4, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create TopK: %v", err)
+	}
+	defer top.StopDecay()
+
+	// Three abusers among many light users
+	for i := 0; i < 5000; i++ {
+		top.Add([]byte(fmt.Sprintf("user_%d", i%500)), 1)
+		switch i % 10 {
+		case 0:
+			top.Add([]byte("abuser_a"), 5)
+		case 1:
+			top.Add([]byte("abuser_b"), 3)
+		case 2:
+			top.Add([]byte("abuser_c"), 2)
+		}
+	}
+
+	list := top.List()
+	want := []string{"abuser_a", "abuser_b", "abuser_c"}
+	if len(list) != len(want) {
+		t.Fatalf("Expected %d heavy hitters, got %v", len(want), list)
+	}
+	for i, key := range want {
+		if list[i].Key != key {
+			t.Errorf("Expected %s at rank %d, got %v", key, i, list)
+		}
+	}
+	if list[0].Count < 2500 {
+		t.Errorf("Expected abuser_a's count to be at least 2500, got %d", list[0].Count)
+	}
+	if got := top.Estimate([]byte("abuser_a")); got != list[0].Count {
+		t.Errorf("Estimate %d differs from listed count %d", got, list[0].Count)
+	}
+}
+
+func TestTopKDecay(t *testing.T) {
+	top, err := NewTopK(2, 256, 4, 0.5, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create TopK: %v", err)
+	}
+	defer top.StopDecay()
+
+	top.Add([]byte("old"), 100)
+	top.Add([]byte("steady"), 10)
+	top.decayCounts()
+	if list := top.List(); list[0].Key != "old" || list[0].Count != 50 {
+		t.Fatalf("Expected old with count 50 after halving, got %v", list)
+	}
+
+	// Keys that stop appearing decay out and are replaced by new heavy hitters
+	for i := 0; i < 10; i++ {
+		top.decayCounts()
+		top.Add([]byte("new"), 20)
+		top.Add([]byte("steady"), 10)
+	}
+	list := top.List()
+	if len(list) != 2 || list[0].Key != "new" || list[1].Key != "steady" {
+		t.Errorf("Expected new and steady after decay, got %v", list)
+	}
+}
+
+func TestTopKInvalidParameters(t *testing.T) {
+	if _, err := NewTopK(0, 1024, 4, 0.5, time.Minute); err == nil {
+		t.Error("Expected an error for k of 0")
+	}
+	if _, err := NewTopK(10, 0, 4, 0.5, time.Minute); err == nil {
+		t.Error("Expected an error for zero width")
+	}
+}
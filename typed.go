@@ -0,0 +1,131 @@
+package sbf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+// Encoder appends the byte representation of a value to buf and returns the extended buffer.
+//
+// Values that are equal must be encoded identically, and values that are different should be
+// encoded differently.
+type Encoder[T any] func(buf []byte, v T) []byte
+
+// Integer is the set of integer types accepted by IntegerEncoder.
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// BinaryAppender is implemented by types that can append their binary representation to a buffer,
+// such as time.Time and netip.Addr. It matches encoding.BinaryAppender from Go 1.24.
+type BinaryAppender interface {
+	AppendBinary(b []byte) ([]byte, error)
+}
+
+// StringEncoder encodes a string as its bytes.
+func StringEncoder[T ~string](buf []byte, v T) []byte {
+	return append(buf, v...)
+}
+
+// BytesEncoder encodes a byte slice as itself.
+func BytesEncoder[T ~[]byte](buf []byte, v T) []byte {
+	return append(buf, v...)
+}
+
+// IntegerEncoder encodes an integer as 8 little-endian bytes of its value converted to uint64, so
+// equal values of different integer types are encoded identically.
+func IntegerEncoder[T Integer](buf []byte, v T) []byte {
+	return binary.LittleEndian.AppendUint64(buf, uint64(v))
+}
+
+// UUIDEncoder encodes a 16-byte UUID, such as a github.com/google/uuid.UUID, as its bytes.
+func UUIDEncoder[T ~[16]byte](buf []byte, v T) []byte {
+	return append(buf, v[:]...)
+}
+
+// binaryAppenderFallback marks values that BinaryAppenderEncoder could not encode with AppendBinary.
+const binaryAppenderFallback = 0xff
+
+// BinaryAppenderEncoder encodes a value with its AppendBinary method.
+//
+// If AppendBinary returns an error, which for the standard library types only happens for values
+// that cannot be represented, such as a time.Time with a sub-minute zone offset, the value is
+// encoded as a 0xff byte followed by its fmt representation instead, so such values can still be
+// added and checked.
+func BinaryAppenderEncoder[T BinaryAppender](buf []byte, v T) []byte {
+	start := len(buf)
+	out, err := v.AppendBinary(buf)
+	if err != nil {
+		// Drop anything AppendBinary wrote before failing
+		buf = append(buf[:start], binaryAppenderFallback)
+		return fmt.Append(buf, v)
+	}
+	return out
+}
+
+// maxPooledBuffer is the largest encoding buffer kept for reuse.
+const maxPooledBuffer = 64 << 10
+
+// Typed is a Filter of values of type T.
+//
+// Values are converted to bytes with an Encoder into a pooled buffer, so Add and Check do not
+// allocate for fixed-size encodings. It is safe for concurrent use by multiple goroutines.
+type Typed[T any] struct {
+	filter  Filter
+	encode  Encoder[T]
+	buffers sync.Pool
+}
+
+// NewTyped wraps a Filter so it can be used with values of type T.
+//
+// Parameters:
+//   - f: Filter that stores the encoded values.
+//   - encode: Encoder that converts values to bytes, e.g. StringEncoder[string].
+//
+// Returns:
+//   - A pointer to the Typed filter.
+func NewTyped[T any](f Filter, encode Encoder[T]) *Typed[T] {
+	t := &Typed[T]{filter: f, encode: encode}
+	t.buffers.New = func() any {
+		buf := make([]byte, 0, 64)
+		return &buf
+	}
+	return t
+}
+
+// Add inserts a value into the filter.
+func (t *Typed[T]) Add(v T) {
+	buf := t.buffers.Get().(*[]byte)
+	*buf = t.encode((*buf)[:0], v)
+	t.filter.Add(*buf)
+	t.release(buf)
+}
+
+// Check tests if a value might be in the filter.
+//
+// Returns true if the value might be in the filter, or false if the value is definitely not in the filter.
+func (t *Typed[T]) Check(v T) bool {
+	buf := t.buffers.Get().(*[]byte)
+	*buf = t.encode((*buf)[:0], v)
+	found := t.filter.Check(*buf)
+	t.release(buf)
+	return found
+}
+
+// Filter returns the underlying filter.
+func (t *Typed[T]) Filter() Filter {
+	return t.filter
+}
+
+// StopDecay stops the decay process of the underlying filter.
+func (t *Typed[T]) StopDecay() {
+	t.filter.StopDecay()
+}
+
+// release returns an encoding buffer to the pool unless it grew too large to keep.
+func (t *Typed[T]) release(buf *[]byte) {
+	if cap(*buf) <= maxPooledBuffer {
+		t.buffers.Put(buf)
+	}
+}
@@ -0,0 +1,132 @@
+package sbf
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+type userID int64
+
+type uuid [16]byte
+
+// oddFailing is a BinaryAppender that cannot encode odd values.
+type oddFailing int
+
+func (v oddFailing) AppendBinary(b []byte) ([]byte, error) {
+	if v%2 != 0 {
+		return append(b, "partial"...), errors.New("odd value")
+	}
+	return IntegerEncoder(b, v), nil
+}
+
+func TestTypedEncoders(t *testing.T) {
+	sbf, err := NewStableBloomFilter(8192, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	defer sbf.StopDecay()
+
+	strings := NewTyped(sbf, StringEncoder[string])
+	strings.Add("alice")
+	if !strings.Check("alice") {
+		t.Error("String missing after Add")
+	}
+
+	ids := NewTyped(sbf, IntegerEncoder[userID])
+	ids.Add(42)
+	if !ids.Check(42) {
+		t.Error("Integer missing after Add")
+	}
+	// Equal integer values share an encoding regardless of type
+	if !NewTyped(sbf, IntegerEncoder[uint64]).Check(42) {
+		t.Error("Expected IntegerEncoder to encode equal values identically")
+	}
+
+	uuids := NewTyped(sbf, UUIDEncoder[uuid])
+	id := uuid{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	uuids.Add(id)
+	if !uuids.Check(id) {
+		t.Error("UUID missing after Add")
+	}
+
+	addrs := NewTyped(sbf, BinaryAppenderEncoder[netip.Addr])
+	addr := netip.MustParseAddr("192.0.2.1")
+	addrs.Add(addr)
+	if !addrs.Check(addr) {
+		t.Error("Address missing after Add")
+	}
+
+	if !sbf.Check([]byte("alice")) {
+		t.Error("StringEncoder should encode a string as its bytes")
+	}
+}
+
+func TestBinaryAppenderEncoderFallback(t *testing.T) {
+	prefix := []byte("prefix")
+	if got := BinaryAppenderEncoder(prefix, oddFailing(4)); string(got) != string(IntegerEncoder(prefix, 4)) {
+		t.Errorf("Expected the AppendBinary encoding, got %x", got)
+	}
+	if got := BinaryAppenderEncoder(prefix, oddFailing(3)); string(got) != "prefix\xff3" {
+		t.Errorf("Expected the fallback encoding, got %q", got)
+	}
+
+	sbf, err := NewStableBloomFilter(8192, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	typed := NewTyped(sbf, BinaryAppenderEncoder[oddFailing])
+	defer typed.StopDecay()
+
+	for v := oddFailing(0); v < 20; v++ {
+		typed.Add(v)
+	}
+	for v := oddFailing(0); v < 20; v++ {
+		if !typed.Check(v) {
+			t.Errorf("Value %d missing after Add", v)
+		}
+	}
+}
+
+func TestTypedMatchesFilter(t *testing.T) {
+	sbf, err := NewStableBloomFilter(1024, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	typed := NewTyped(sbf, StringEncoder[string])
+	defer typed.StopDecay()
+
+	for i := 0; i < 100; i++ {
+		typed.Add(fmt.Sprintf("element_%d", i))
+	}
+	for i := 0; i < 200; i++ {
+		data := fmt.Sprintf("element_%d", i)
+		if typed.Check(data) != typed.Filter().Check([]byte(data)) {
+			t.Errorf("Typed and raw Check disagree for %q", data)
+		}
+	}
+}
+
+func TestTypedNoAllocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops items at random under the race detector")
+	}
+	sbf, err := NewStableBloomFilter(8192, nil, 0.0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+	typed := NewTyped(sbf, IntegerEncoder[int64])
+	defer typed.StopDecay()
+
+	var i int64
+	allocs := testing.AllocsPerRun(1000, func() {
+		typed.Add(i)
+		typed.Check(i)
+		i++
+	})
+	if allocs > 0 {
+		t.Errorf("Expected no allocations per Add and Check, got %.1f", allocs)
+	}
+}
@@ -0,0 +1,212 @@
+package sbf
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WindowedFilter is a Bloom filter over a sliding window of time or insertions.
+//
+// It keeps G generations of bit arrays, laid out like the StableBloomFilter's. Add writes to the
+// newest generation, Check tests every generation, and rotating clears the oldest generation and
+// makes it the newest. Unlike the probabilistic decay of StableBloomFilter, retention is
+// deterministic: an element is remembered for at least the window, and forgotten at most one
+// generation span after the window has passed.
+//
+// It is safe for concurrent use by multiple goroutines.
+type WindowedFilter struct {
+	m           uint32     // Size of each generation in bits
+	k           uint32     // Number of hash functions
+	hashFuncs   []Hash64   // Slice of hash functions
+	generations [][]uint64 // Bit arrays, generations[current] is the newest
+	current     atomic.Uint32
+
+	span          time.Duration // Time between rotations, 0 for count-based windows
+	perGeneration uint64        // Adds between rotations, 0 for time-based windows
+	adds          atomic.Uint64 // Adds since the last count-based rotation
+
+	rotateMu  sync.Mutex
+	rotations atomic.Uint64
+	rotator   periodic
+}
+
+// NewWindowedFilter creates a Bloom filter that remembers elements for a sliding time window.
+//
+// The filter rotates every window/(generations-1), so an element is remembered for at least window
+// and at most window + window/(generations-1). More generations tighten that bound at the cost of
+// memory and Check time. If hashFuncs is empty, it uses default hash functions based on zeebo/xxh3.
+//
+// Parameters:
+//   - m: Size of each generation in bits.
+//   - hashFuncs: Slice of hash functions to use. If empty, default hash functions are used.
+//   - generations: Number of generations (at least 2).
+//   - window: Minimum time an element is remembered.
+//
+// Returns:
+//   - A pointer to the WindowedFilter.
+//   - An error if the parameters are invalid.
+func NewWindowedFilter(m uint32, hashFuncs []Hash64, generations int, window time.Duration) (*WindowedFilter, error) {
+	if window <= 0 {
+		return nil, errors.New("window must be positive")
+	}
+	w, err := newWindowedFilter(m, hashFuncs, generations)
+	if err != nil {
+		return nil, err
+	}
+	w.span = window / time.Duration(generations-1)
+	if w.span <= 0 {
+		return nil, errors.New("window is too short for the number of generations")
+	}
+	w.rotator.start(w.span, w.Rotate)
+	return w, nil
+}
+
+// NewCountWindowedFilter creates a Bloom filter that remembers the elements of a sliding window of
+// insertions.
+//
+// The filter rotates after every ceil(windowItems/(generations-1)) calls to Add, so an element is
+// remembered for at least the following windowItems insertions. No background goroutine is used.
+//
+// Parameters:
+//   - m: Size of each generation in bits.
+//   - hashFuncs: Slice of hash functions to use. If empty, default hash functions are used.
+//   - generations: Number of generations (at least 2).
+//   - windowItems: Minimum number of insertions an element is remembered for.
+//
+// Returns:
+//   - A pointer to the WindowedFilter.
+//   - An error if the parameters are invalid.
+func NewCountWindowedFilter(m uint32, hashFuncs []Hash64, generations int, windowItems uint64) (*WindowedFilter, error) {
+	if windowItems == 0 {
+		return nil, errors.New("window must contain at least one item")
+	}
+	w, err := newWindowedFilter(m, hashFuncs, generations)
+	if err != nil {
+		return nil, err
+	}
+	g := uint64(generations - 1)
+	w.perGeneration = (windowItems + g - 1) / g
+	return w, nil
+}
+
+// newWindowedFilter creates a WindowedFilter without a rotation policy.
+func newWindowedFilter(m uint32, hashFuncs []Hash64, generations int) (*WindowedFilter, error) {
+	if m == 0 {
+		return nil, errors.New("filter size m must be greater than 0")
+	}
+	if generations < 2 {
+		return nil, errors.New("number of generations must be at least 2")
+	}
+	if len(hashFuncs) == 0 {
+		hashFuncs, _ = defaultHashFuncs(7)
+	}
+
+	// Ensure m is a multiple of 64 for alignment
+	if m%64 != 0 {
+		m += 64 - (m % 64)
+	}
+
+	w := &WindowedFilter{
+		m:           m,
+		k:           uint32(len(hashFuncs)),
+		hashFuncs:   hashFuncs,
+		generations: make([][]uint64, generations),
+	}
+	for i := range w.generations {
+		w.generations[i] = make([]uint64, m/64)
+	}
+	return w, nil
+}
+
+// Add inserts an element into the newest generation.
+func (w *WindowedFilter) Add(data []byte) {
+	gen := w.generations[w.current.Load()]
+	for i := uint32(0); i < w.k; i++ {
+		idx := w.hashIndex(data, i)
+		atomicOr(&gen[idx/64], uint64(1)<<(idx%64))
+	}
+
+	if w.perGeneration != 0 && w.adds.Add(1)%w.perGeneration == 0 {
+		w.Rotate()
+	}
+}
+
+// Check tests if an element might have been added within the window.
+//
+// Returns true if the element might be in the filter, or false if the element is definitely not in the filter.
+func (w *WindowedFilter) Check(data []byte) bool {
+	var idx [16]uint32
+	indexes := idx[:0]
+	for i := uint32(0); i < w.k; i++ {
+		indexes = append(indexes, w.hashIndex(data, i))
+	}
+
+	for _, gen := range w.generations {
+		found := true
+		for _, idx := range indexes {
+			if !atomicGetBit(&gen[idx/64], idx%64) {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// Rotate clears the oldest generation and makes it the newest.
+//
+// Rotation happens automatically on the window's boundaries; Rotate is exported to let callers
+// align windows with external events.
+func (w *WindowedFilter) Rotate() {
+	w.rotateMu.Lock()
+	defer w.rotateMu.Unlock()
+
+	next := (w.current.Load() + 1) % uint32(len(w.generations))
+	gen := w.generations[next]
+	for i := range gen {
+		atomic.StoreUint64(&gen[i], 0)
+	}
+	w.current.Store(next)
+	w.rotations.Add(1)
+}
+
+// Rotations returns the number of rotations since the filter was created.
+func (w *WindowedFilter) Rotations() uint64 {
+	return w.rotations.Load()
+}
+
+// EstimateFalsePositiveRate estimates the current false positive rate of the WindowedFilter.
+//
+// An element is reported present if any generation reports it, so the estimate is
+// 1 - ∏(1 - fᵢᵏ), where fᵢ is the fraction of bits set in generation i.
+func (w *WindowedFilter) EstimateFalsePositiveRate() float64 {
+	miss := 1.0
+	for _, gen := range w.generations {
+		var bitsSet uint64
+		for i := range gen {
+			bitsSet += uint64(bits.OnesCount64(atomic.LoadUint64(&gen[i])))
+		}
+		miss *= 1 - math.Pow(float64(bitsSet)/float64(w.m), float64(w.k))
+	}
+	return 1 - miss
+}
+
+// StopDecay stops the rotation process of a time-based WindowedFilter.
+//
+// This function should be called when the filter is no longer needed to clean up resources. It
+// has no effect on count-based filters.
+func (w *WindowedFilter) StopDecay() {
+	w.rotator.stop()
+}
+
+// hashIndex computes the hash index for the i-th hash function.
+func (w *WindowedFilter) hashIndex(data []byte, i uint32) uint32 {
+	return uint32(w.hashFuncs[i](data) % uint64(w.m))
+}
@@ -0,0 +1,100 @@
+package sbf
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCountWindowedFilterRetention(t *testing.T) {
+	const window = 1000
+	w, err := NewCountWindowedFilter(1<<16, nil, 5, window)
+	if err != nil {
+		t.Fatalf("Failed to create WindowedFilter: %v", err)
+	}
+	defer w.StopDecay()
+
+	w.Add([]byte("target"))
+	for i := 0; i < window; i++ {
+		if !w.Check([]byte("target")) {
+			t.Fatalf("Element forgotten after %d insertions, window is %d", i, window)
+		}
+		w.Add([]byte(fmt.Sprintf("element_%d", i)))
+	}
+
+	// Forgotten at most one generation (window/4 insertions) after the window
+	for i := 0; i < window/4; i++ {
+		w.Add([]byte(fmt.Sprintf("later_%d", i)))
+	}
+	if w.Check([]byte("target")) {
+		t.Error("Element still present one generation after the window")
+	}
+	if w.Rotations() != 5 {
+		t.Errorf("Expected 5 rotations, got %d", w.Rotations())
+	}
+}
+
+func TestWindowedFilterRotate(t *testing.T) {
+	w, err := NewWindowedFilter(1024, nil, 3, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create WindowedFilter: %v", err)
+	}
+	defer w.StopDecay()
+
+	w.Add([]byte("element"))
+	w.Rotate()
+	w.Rotate()
+	if !w.Check([]byte("element")) {
+		t.Error("Element forgotten before its generation was cleared")
+	}
+	if fpr := w.EstimateFalsePositiveRate(); fpr <= 0 || fpr >= 1 {
+		t.Errorf("Expected an FPR between 0 and 1, got %f", fpr)
+	}
+
+	w.Rotate()
+	if w.Check([]byte("element")) {
+		t.Error("Element still present after every generation rotated")
+	}
+	if fpr := w.EstimateFalsePositiveRate(); fpr != 0 {
+		t.Errorf("Expected FPR 0 for an empty filter, got %f", fpr)
+	}
+}
+
+func TestWindowedFilterTimeRotation(t *testing.T) {
+	w, err := NewWindowedFilter(1024, nil, 3, 40*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to create WindowedFilter: %v", err)
+	}
+	defer w.StopDecay()
+
+	w.Add([]byte("element"))
+	if !w.Check([]byte("element")) {
+		t.Fatal("Element missing right after Add")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for w.Check([]byte("element")) {
+		if time.Now().After(deadline) {
+			t.Fatal("Element was never forgotten")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if w.Rotations() < 3 {
+		t.Errorf("Element forgotten after only %d rotations", w.Rotations())
+	}
+}
+
+func TestWindowedFilterInvalidParameters(t *testing.T) {
+	if _, err := NewWindowedFilter(1024, nil, 1, time.Minute); err == nil {
+		t.Error("Expected an error for a single generation")
+	}
+	if _, err := NewWindowedFilter(1024, nil, 3, 0); err == nil {
+		t.Error("Expected an error for a zero window")
+	}
+	if _, err := NewCountWindowedFilter(0, nil, 3, 100); err == nil {
+		t.Error("Expected an error for a zero size")
+	}
+	if _, err := NewCountWindowedFilter(1024, nil, 3, 0); err == nil {
+		t.Error("Expected an error for an empty window")
+	}
+}